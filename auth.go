@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	netURL "net/url"
+	"strings"
+	"time"
+
+	"github.com/eventials/go-tus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cspTokenPath        = "/csp/gateway/am/api/auth/token"
+	jwtBearerAssertion  = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	assertionExpiration = 5 * time.Minute
+)
+
+// addClientAuthFlags registers the flags of the service account login: a client id authenticated
+// with a private-key-signed JWT assertion or with a TLS client certificate.
+func addClientAuthFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.String("client-id", "", "OAuth client id of a service account logging in CSP/vIDM with --assertion-key or --client-cert instead of a password")
+	flags.String("assertion-key", "", "PEM RSA or EC private key signing the JWT client assertion of the --client-id")
+	flags.String("client-cert", "", "PEM client certificate presented to the servers, with --client-key")
+	flags.String("client-key", "", "PEM private key of the --client-cert")
+	flags.String("token-url", "", "OAuth token endpoint of the --client-id login. Defaults to "+cspTokenPath+" on the host of the target")
+}
+
+func parseClientAuth(cmd *cobra.Command, opts *options) error {
+	flags := cmd.Flags()
+	var err error
+	if opts.clientID, err = flags.GetString("client-id"); err != nil {
+		return err
+	}
+	if opts.tokenURL, err = flags.GetString("token-url"); err != nil {
+		return err
+	}
+	assertionKey, err := flags.GetString("assertion-key")
+	if err != nil {
+		return err
+	}
+	if assertionKey != "" {
+		if opts.assertionKey, err = loadPrivateKey(assertionKey); err != nil {
+			return err
+		}
+	}
+	clientCert, err := flags.GetString("client-cert")
+	if err != nil {
+		return err
+	}
+	clientKey, err := flags.GetString("client-key")
+	if err != nil {
+		return err
+	}
+	if clientCert != "" || clientKey != "" {
+		if clientCert == "" || clientKey == "" {
+			return fmt.Errorf("--client-cert and --client-key go together")
+		}
+		certificate, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return fmt.Errorf("Unable to load the client certificate %s: %s", clientCert, err.Error())
+		}
+		opts.clientCertificate = &certificate
+	}
+	if opts.clientID == "" && opts.assertionKey != nil {
+		return fmt.Errorf("--assertion-key requires a --client-id")
+	}
+	if opts.clientID != "" && opts.assertionKey == nil && opts.clientCertificate == nil {
+		return fmt.Errorf("--client-id requires an --assertion-key or a --client-cert")
+	}
+	return nil
+}
+
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("No PEM private key in %s", path)
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("Unsupported private key in %s: it must be an RSA or EC key", path)
+}
+
+// clientAssertion returns a JWT signed by key, RS256 for an RSA key or ES256 for an EC P-256 key.
+func clientAssertion(clientID, audience string, key crypto.Signer, certificate *tls.Certificate) (string, error) {
+	header := map[string]string{"typ": "JWT"}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		header["alg"] = "RS256"
+	case *ecdsa.PrivateKey:
+		if k.Curve.Params().BitSize != 256 {
+			return "", fmt.Errorf("Unsupported EC key: the assertion is signed with ES256 which requires a P-256 key")
+		}
+		header["alg"] = "ES256"
+	default:
+		return "", fmt.Errorf("Unsupported private key: it must be an RSA or EC key")
+	}
+	if certificate != nil && len(certificate.Certificate) > 0 {
+		thumbprint := sha1.Sum(certificate.Certificate[0])
+		header["x5t"] = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": clientID,
+		"sub": clientID,
+		"aud": audience,
+		"jti": hex.EncodeToString(jti),
+		"iat": now.Unix(),
+		"exp": now.Add(assertionExpiration).Unix(),
+	}
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." + base64.RawURLEncoding.EncodeToString(encodedClaims)
+	digest := sha256.Sum256([]byte(signingInput))
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			return "", err
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return "", err
+		}
+		// JWS wants the fixed size concatenation of r and s rather than ASN.1
+		signature = append(padBigInt(r, 32), padBigInt(s, 32)...)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func padBigInt(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// vraClientToken logs a service account in with the OAuth client credentials grant, authenticated
+// with a JWT client assertion or, without an assertion key, with the TLS client certificate.
+func vraClientToken(opts *options, client *tus.Client, clientConfig *tus.Config) (string, error) {
+	tokenURL := opts.tokenURL
+	if tokenURL == "" {
+		baseURL, err := netURL.Parse(client.Url)
+		if err != nil {
+			return "", err
+		}
+		tokenURL = baseURL.Scheme + "://" + baseURL.Host + cspTokenPath
+	}
+	form := netURL.Values{
+		"grant_type": {"client_credentials"},
+		"client_id":  {opts.clientID},
+	}
+	if opts.assertionKey != nil {
+		assertion, err := clientAssertion(opts.clientID, tokenURL, opts.assertionKey, opts.clientCertificate)
+		if err != nil {
+			return "", err
+		}
+		form.Set("client_assertion_type", jwtBearerAssertion)
+		form.Set("client_assertion", assertion)
+	}
+	response, err := clientConfig.HttpClient.Post(tokenURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode != 200 {
+		return "", fmt.Errorf("Failed to login the client %s on %s: %s", opts.clientID, tokenURL, string(body))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("No access_token in the response of %s", tokenURL)
+	}
+	return token.AccessToken, nil
+}
+
+// vraLogin returns the token of the service account when there is a --client-id, otherwise of the user.
+func vraLogin(opts *options, client *tus.Client, clientConfig *tus.Config) (string, error) {
+	if opts.clientID != "" {
+		return vraClientToken(opts, client, clientConfig)
+	}
+	return vraToken(opts.vraUser, opts.vraPassword, client, clientConfig)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	netURL "net/url"
+	"sync"
+
+	"github.com/eventials/go-tus"
+)
+
+// uploadJob is one file to upload to the target of a client.
+type uploadJob struct {
+	file   string
+	client *tus.Client
+	// environment is the name of the target in the --environments inventory
+	environment string
+	// loginErr is the failure of the login of the environment, reported as the failure of the job
+	loginErr error
+	// dependsOn are the files of the --release-manifest that must be imported before this one
+	dependsOn []string
+}
+
+// jobKey identifies the upload of a file to a target.
+type jobKey struct {
+	file   string
+	client *tus.Client
+}
+
+// hostLimiter caps the number of concurrent uploads to the same host.
+type hostLimiter struct {
+	max   int
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// acquire takes a slot of the host of target for a job holding a slot of parallel. While the host is busy, it gives
+// back the slot of parallel so that the jobs of the other hosts run meanwhile, and reports that it waited.
+func (l *hostLimiter) acquire(target string, parallel chan struct{}) (func(), bool) {
+	if l.max <= 0 {
+		return func() {}, false
+	}
+	host := target
+	if u, err := netURL.Parse(target); err == nil {
+		host = u.Host
+	}
+	l.mu.Lock()
+	slot, ok := l.slots[host]
+	if !ok {
+		slot = make(chan struct{}, l.max)
+		l.slots[host] = slot
+	}
+	l.mu.Unlock()
+	waited := false
+	select {
+	case slot <- struct{}{}:
+	default:
+		<-parallel
+		slot <- struct{}{}
+		parallel <- struct{}{}
+		waited = true
+	}
+	return func() { <-slot }, waited
+}
+
+// runBatch uploads the jobs with up to opts.parallel concurrent uploads and opts.maxPerHost per host.
+// Unless opts.continueOnError, no new upload is started in an environment after a failure in that environment. The
+// environments run independently: the failed login of one is reported as the failure of its jobs only.
+// It returns the result of each job, nil when the upload was not started.
+func runBatch(jobs []uploadJob, opts *options) []*uploadResult {
+	parallel := opts.parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	slots := make(chan struct{}, parallel)
+	hosts := &hostLimiter{max: opts.maxPerHost, slots: make(map[string]chan struct{})}
+	results := make([]*uploadResult, len(jobs))
+	byKey := make(map[jobKey]int, len(jobs))
+	for i, job := range jobs {
+		byKey[jobKey{job.file, job.client}] = i
+	}
+
+	var mu sync.Mutex
+	// failed are the environments with a failed upload
+	failed := make(map[string]bool)
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		slots <- struct{}{}
+		if isInterrupted() {
+			<-slots
+			break
+		}
+		mu.Lock()
+		skip := failed[job.environment] && !opts.continueOnError
+		mu.Unlock()
+		if skip {
+			<-slots
+			continue
+		}
+		wg.Add(1)
+		go func(i int, job uploadJob) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			release, waited := hosts.acquire(job.client.Url, slots)
+			defer release()
+			if waited {
+				mu.Lock()
+				stop := (failed[job.environment] && !opts.continueOnError) || isInterrupted()
+				mu.Unlock()
+				if stop {
+					return
+				}
+			}
+
+			skipErr := job.loginErr
+			if skipErr == nil {
+				mu.Lock()
+				skipErr = failedDependency(job, results, byKey)
+				mu.Unlock()
+			}
+			var result *uploadResult
+			var err error
+			if skipErr != nil {
+				result, err = &uploadResult{File: job.file, Target: job.client.Url}, skipErr
+			} else {
+				result, err = uploadFile(job.file, job.client, opts)
+			}
+			for restarts := 1; errors.Is(err, errSourceChanged) && opts.onChange == "restart" && restarts <= maxSourceRestarts; restarts++ {
+				console.Printf("%s changed during the upload, restarting it (%d of %d)\n", job.file, restarts, maxSourceRestarts)
+				result, err = uploadFile(job.file, job.client, opts)
+			}
+			result.Environment = job.environment
+			console.Finished(job.file, err)
+			if err != nil {
+				result.Status = "failed"
+				result.Error = err.Error()
+				if totals != nil {
+					totals.fail(job.file)
+				}
+				if opts.continueOnError {
+					console.Printf("Failed to upload %s: %s\n", job.file, err.Error())
+				}
+			}
+			mu.Lock()
+			results[i] = result
+			if err != nil && job.loginErr == nil {
+				failed[job.environment] = true
+			}
+			mu.Unlock()
+		}(i, job)
+	}
+	wg.Wait()
+	return results
+}
+
+// failedDependency reports the first dependency of the job that was not uploaded and imported.
+// The dependencies are uploaded before a job as a --release-manifest is uploaded one file after the other.
+func failedDependency(job uploadJob, results []*uploadResult, byKey map[jobKey]int) error {
+	for _, dependency := range job.dependsOn {
+		result := results[byKey[jobKey{dependency, job.client}]]
+		if result == nil || result.Error != "" || (result.ImportStatus != "" && result.ImportStatus != "imported") {
+			return fmt.Errorf("Skipped %s: its dependency %s failed to upload or import", job.file, dependency)
+		}
+	}
+	return nil
+}
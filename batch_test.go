@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eventials/go-tus"
+)
+
+func TestRunBatchEnvironments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var files []string
+	for _, name := range []string{"a.zip", "b.zip"} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+	server := httptest.NewServer(&mockServer{uploads: make(map[string]*mockUpload), failedImports: make(map[string]string)})
+	defer server.Close()
+	newClient := func(path string) *tus.Client {
+		client, err := tus.NewClient(server.URL+path, tus.DefaultConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	for _, test := range []struct {
+		name string
+		// envs are the paths of the targets of the environments
+		envs map[string]string
+		// order of the environments in the batch
+		order []string
+		// loginFails is the environment that did not log in
+		loginFails string
+		// failed and uploaded are the expected status of the jobs per environment
+		failed   map[string]int
+		uploaded map[string]int
+	}{
+		{"failed login first", map[string]string{"bad": mockUploadsPath, "good": mockUploadsPath}, []string{"bad", "good"}, "bad",
+			map[string]int{"bad": 2}, map[string]int{"good": 2}},
+		{"failed login last", map[string]string{"good": mockUploadsPath, "bad": mockUploadsPath}, []string{"good", "bad"}, "bad",
+			map[string]int{"bad": 2}, map[string]int{"good": 2}},
+		{"failed upload", map[string]string{"bad": "/nope/", "good": mockUploadsPath}, []string{"bad", "good"}, "",
+			map[string]int{"bad": 1}, map[string]int{"good": 2}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var jobs []uploadJob
+			for _, env := range test.order {
+				var loginErr error
+				if env == test.loginFails {
+					loginErr = errors.New("Unable to log in the environment " + env)
+				}
+				client := newClient(test.envs[env])
+				for _, file := range files {
+					jobs = append(jobs, uploadJob{file: file, client: client, environment: env, loginErr: loginErr})
+				}
+			}
+			results := runBatch(jobs, &options{parallel: 1, checksumAlgo: "sha256", resumeOffset: -1})
+
+			failed, uploaded := make(map[string]int), make(map[string]int)
+			for _, result := range results {
+				if result == nil {
+					continue
+				}
+				if result.Error != "" {
+					failed[result.Environment]++
+				} else if result.Status == "uploaded" {
+					uploaded[result.Environment]++
+				}
+			}
+			for _, env := range test.order {
+				if failed[env] != test.failed[env] || uploaded[env] != test.uploaded[env] {
+					t.Errorf("%s: %d failed and %d uploaded instead of %d and %d", env, failed[env], uploaded[env], test.failed[env], test.uploaded[env])
+				}
+			}
+		})
+	}
+}
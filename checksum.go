@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func newHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "crc32":
+		return crc32.NewIEEE(), nil
+	}
+	return nil, fmt.Errorf("Unsupported checksum algorithm '%s'. It must be one of sha1, sha256, sha512 or crc32", algo)
+}
+
+// fileDigest returns the hex encoded digest of a file.
+func fileDigest(path, algo string) (string, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// streamDigest hashes a file while the uploader reads it, so that the checksum features do not read it a second time.
+// The uploader seeks back to retry a chunk: the bytes already hashed are not hashed again.
+type streamDigest struct {
+	stream io.ReadSeeker
+	hash   hash.Hash
+	pos    int64
+	hashed int64
+	// skipped is set when a read started past the bytes hashed, eg when resuming an upload
+	skipped bool
+}
+
+func newStreamDigest(stream io.ReadSeeker, algo string) (*streamDigest, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return nil, err
+	}
+	return &streamDigest{stream: stream, hash: h}, nil
+}
+
+func (s *streamDigest) Read(p []byte) (int, error) {
+	n, err := s.stream.Read(p)
+	start, end := s.pos, s.pos+int64(n)
+	if start > s.hashed {
+		s.skipped = true
+	} else if !s.skipped && end > s.hashed {
+		s.hash.Write(p[s.hashed-start : n])
+		s.hashed = end
+	}
+	s.pos = end
+	return n, err
+}
+
+func (s *streamDigest) Seek(offset int64, whence int) (int64, error) {
+	pos, err := s.stream.Seek(offset, whence)
+	if err == nil {
+		s.pos = pos
+	}
+	return pos, err
+}
+
+// fileDigest returns the hex encoded digest of the file of size Bytes that was read through s, reading it again
+// only when s is nil or did not read all of it.
+func (s *streamDigest) fileDigest(path, algo string, size int64) (string, error) {
+	if s == nil || s.skipped || s.hashed != size {
+		return fileDigest(path, algo)
+	}
+	return hex.EncodeToString(s.hash.Sum(nil)), nil
+}
+
+// writeChecksumSidecar writes <path>.<algo> in the format of sha256sum and friends.
+func writeChecksumSidecar(path, algo, digest string) (string, error) {
+	sidecar := path + "." + algo
+	content := fmt.Sprintf("%s  %s\n", digest, filepath.Base(path))
+	return sidecar, ioutil.WriteFile(sidecar, []byte(content), 0644)
+}
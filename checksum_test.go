@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, size int) string {
+	content := make([]byte, size)
+	rand.Read(content)
+	dir, err := ioutil.TempDir("", "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "bundle.zip")
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readChunks reads the stream like the uploader: a seek to the offset of each chunk, then a read.
+func readChunks(t *testing.T, stream io.ReadSeeker, offsets []int64, chunkSize int) {
+	buf := make([]byte, chunkSize)
+	for _, offset := range offsets {
+		if _, err := stream.Seek(offset, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stream.Read(buf); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStreamDigest(t *testing.T) {
+	const size, chunk = 10000, 4096
+	path := writeTestFile(t, size)
+	for _, test := range []struct {
+		name     string
+		offsets  []int64
+		complete bool
+	}{
+		{"sequential", []int64{0, 4096, 8192}, true},
+		{"retried chunk", []int64{0, 4096, 4096, 8192}, true},
+		{"retried from the start", []int64{0, 4096, 0, 4096, 8192}, true},
+		{"resumed upload", []int64{4096, 8192}, false},
+		{"interrupted upload", []int64{0, 4096}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			for _, algo := range []string{"sha256", "sha1", "crc32"} {
+				f, err := os.Open(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				s, err := newStreamDigest(f, algo)
+				if err != nil {
+					t.Fatal(err)
+				}
+				readChunks(t, s, test.offsets, chunk)
+				f.Close()
+
+				complete := !s.skipped && s.hashed == size
+				if complete != test.complete {
+					t.Errorf("%s: hashed %d Bytes, skipped %v, expected a complete digest: %v", algo, s.hashed, s.skipped, test.complete)
+				}
+				// the fallback reads the file again: the closed stream is not used
+				got, err := s.fileDigest(path, algo, size)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, err := fileDigest(path, algo)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("%s: digest %s instead of %s", algo, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamDigestNil(t *testing.T) {
+	path := writeTestFile(t, 100)
+	var s *streamDigest
+	got, err := s.fileDigest(path, "sha256", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := fileDigest(path, "sha256"); got != want {
+		t.Errorf("digest %s instead of %s", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/eventials/go-tus"
+)
+
+// teamcityEscape escapes the values of TeamCity service messages.
+func teamcityEscape(s string) string {
+	return strings.NewReplacer("|", "||", "'", "|'", "\n", "|n", "\r", "|r", "[", "|[", "]", "|]").Replace(s)
+}
+
+// teamcityReporter emits TeamCity service messages so that the build shows the live progress.
+type teamcityReporter struct {
+	*plainReporter
+	blocks sync.Map
+}
+
+func (r *teamcityReporter) Progress(name string, upload tus.Upload) {
+	r.closeBlock(name)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if totals != nil {
+		totals.update(name, upload.Offset())
+	}
+	if !r.throttle.due(name, upload) {
+		return
+	}
+	fmt.Fprintf(r.out, "##teamcity[progressMessage '%s']\n", teamcityEscape(fmt.Sprintf("Uploading %s: %v%% %v Bytes of %v Bytes", name, upload.Progress(), upload.Offset(), upload.Size())))
+	if totals != nil {
+		fmt.Fprintf(r.out, "##teamcity[progressMessage '%s']\n", teamcityEscape(totals.String()))
+	}
+}
+
+func (r *teamcityReporter) Retry(name string, attempt, attempts int) {
+	if _, open := r.blocks.LoadOrStore(name, true); !open {
+		r.Printf("##teamcity[blockOpened name='%s']\n", teamcityEscape("Retrying "+name))
+	}
+	r.plainReporter.Retry(name, attempt, attempts)
+}
+
+func (r *teamcityReporter) closeBlock(name string) {
+	if _, open := r.blocks.Load(name); open {
+		r.blocks.Delete(name)
+		r.Printf("##teamcity[blockClosed name='%s']\n", teamcityEscape("Retrying "+name))
+	}
+}
+
+func (r *teamcityReporter) Finished(name string, err error) {
+	r.closeBlock(name)
+	if err != nil {
+		r.Printf("##teamcity[buildProblem description='%s' identity='%s']\n", teamcityEscape("Failed to upload "+name+": "+err.Error()), teamcityEscape("tus-upload-"+name))
+		return
+	}
+	r.Printf("##teamcity[progressMessage '%s']\n", teamcityEscape("Uploaded "+name))
+}
+
+// jenkinsReporter prefixes the lines with the severities matched by the default rules of the Jenkins Log Parser plugin
+// and tags the progress lines so that they can be filtered in the console.
+type jenkinsReporter struct {
+	*plainReporter
+}
+
+func (r *jenkinsReporter) Printf(format string, args ...interface{}) {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(fmt.Sprintf(format, args...), "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "Error") || strings.HasPrefix(line, "Failed"):
+			b.WriteString("ERROR: " + line + "\n")
+		case strings.HasPrefix(line, "Warning"):
+			b.WriteString("WARNING: " + strings.TrimPrefix(line, "Warning: ") + "\n")
+		default:
+			b.WriteString("INFO: " + line + "\n")
+		}
+	}
+	r.plainReporter.Printf("%s", b.String())
+}
+
+func (r *jenkinsReporter) Progress(name string, upload tus.Upload) {
+	r.mu.Lock()
+	if totals != nil {
+		totals.update(name, upload.Offset())
+	}
+	due := r.throttle.due(name, upload)
+	r.mu.Unlock()
+	if due {
+		r.Printf("[tus-uploader] %s %v%% (%v of %v Bytes)\n", name, upload.Progress(), upload.Offset(), upload.Size())
+		if totals != nil {
+			r.Printf("[tus-uploader] %s\n", totals)
+		}
+	}
+}
+
+func (r *jenkinsReporter) Retry(name string, attempt, attempts int) {
+	r.Printf("Warning: attempt %v of %v for %s\n", attempt, attempts, name)
+}
+
+func (r *jenkinsReporter) Finished(name string, err error) {}
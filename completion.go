@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion [bash|zsh|fish|powershell]",
+		Short: "Generate the shell completion script",
+		Long: `Outputs the completion script for the given shell.
+
+Bash:
+  $ source <(tus-uploader completion bash)
+
+Zsh:
+  $ tus-uploader completion zsh > "${fpath[1]}/_tus-uploader"
+
+Fish:
+  $ tus-uploader completion fish > ~/.config/fish/completions/tus-uploader.fish
+
+PowerShell:
+  PS> tus-uploader completion powershell | Out-String | Invoke-Expression
+`,
+		ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+		Args:      cobra.ExactValidArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletion(os.Stdout)
+			case "zsh":
+				return root.GenZshCompletion(os.Stdout)
+			case "fish":
+				return root.GenFishCompletion(os.Stdout, true)
+			default:
+				return root.GenPowerShellCompletion(os.Stdout)
+			}
+		},
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Work with the files that configure the uploads",
+		Args:  cobra.NoArgs,
+	}
+	validate := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the release manifest, environments inventory and metadata file without uploading anything",
+		Long: `Parses the files of --release-manifest, --environments and --metadata-file like an upload does, checks that the
+bundles they reference exist and that the password of each environment can be read, and prints a checklist.
+Exits with 1 when a check fails.`,
+		Args: cobra.NoArgs,
+		RunE: configValidate,
+	}
+	validate.Flags().String("release-manifest", "", "YAML or JSON file listing several bundles and their dependencies")
+	validate.Flags().String("environments", "", "YAML or JSON inventory of vRA environments")
+	validate.Flags().String("metadata-file", "", "JSON or YAML file with the TUS metadata and extra fields of the vRA import payload")
+	cmd.AddCommand(validate)
+	return cmd
+}
+
+func configValidate(cmd *cobra.Command, args []string) error {
+	releaseManifest, err := cmd.Flags().GetString("release-manifest")
+	if err != nil {
+		return err
+	}
+	environments, err := cmd.Flags().GetString("environments")
+	if err != nil {
+		return err
+	}
+	metadataFile, err := cmd.Flags().GetString("metadata-file")
+	if err != nil {
+		return err
+	}
+	if releaseManifest == "" && environments == "" && metadataFile == "" {
+		return fmt.Errorf("Nothing to validate: set --release-manifest, --environments or --metadata-file")
+	}
+	report := &doctorReport{}
+	validateConfig(report, releaseManifest, environments, metadataFile)
+	if report.failures > 0 {
+		return fmt.Errorf("%d checks failed", report.failures)
+	}
+	return nil
+}
+
+// validateConfig checks the files of a run the way the upload loads them. The passwords are read but never printed.
+func validateConfig(report *doctorReport, releaseManifest, environments, metadataFile string) {
+	if releaseManifest != "" {
+		if bundles, err := loadReleaseManifest(releaseManifest); err != nil {
+			report.check("FAIL", "Release", "%s", err.Error())
+		} else {
+			names := make([]string, len(bundles))
+			for i, bundle := range bundles {
+				names[i] = bundle.Name
+			}
+			report.check("PASS", "Release", "%s: %d bundles, imported in the order %s", releaseManifest, len(bundles), strings.Join(names, ", "))
+		}
+	}
+
+	if environments != "" {
+		if envs, err := loadEnvironments(environments); err != nil {
+			report.check("FAIL", "Env", "%s", err.Error())
+		} else {
+			for _, env := range envs {
+				validateEnvironment(report, env)
+			}
+		}
+	}
+
+	if metadataFile != "" {
+		if loaded, err := loadMetadataFile(metadataFile); err != nil {
+			report.check("FAIL", "Metadata", "%s", err.Error())
+		} else {
+			report.check("PASS", "Metadata", "%s: %d metadata and %d import fields", metadataFile, len(loaded.Metadata), len(loaded.Import))
+		}
+	}
+}
+
+// validateEnvironment checks that the credentials of the login to an environment can be read.
+func validateEnvironment(report *doctorReport, env environment) {
+	envOpts, err := environmentOptions(env, &options{})
+	switch {
+	case err != nil:
+		report.check("FAIL", "Env", "%s", err.Error())
+	case env.PasswordEnv != "" && envOpts.vraPassword == "":
+		report.check("FAIL", "Env", "The environment variable %s of the password of the environment '%s' is empty", env.PasswordEnv, env.Name)
+	case env.PasswordFile != "" && envOpts.vraPassword == "":
+		report.check("FAIL", "Env", "The password file %s of the environment '%s' is empty", env.PasswordFile, env.Name)
+	case env.Username == "":
+		report.check("WARN", "Env", "%s: %s with the --vra-username and --vra-password of the command line", env.Name, env.Target)
+	case env.PasswordEnv != "":
+		report.check("PASS", "Env", "%s: %s as %s with the password of $%s", env.Name, env.Target, env.Username, env.PasswordEnv)
+	case env.PasswordFile != "":
+		report.check("PASS", "Env", "%s: %s as %s with the password of %s", env.Name, env.Target, env.Username, env.PasswordFile)
+	default:
+		report.check("WARN", "Env", "%s: %s as %s with the --vra-password of the command line", env.Name, env.Target, env.Username)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("base.zip", "base")
+	write("password", "secret\n")
+	write("empty-password", "\n")
+	os.Setenv("TUS_TEST_PASSWORD", "secret")
+	defer os.Unsetenv("TUS_TEST_PASSWORD")
+	os.Setenv("TUS_TEST_EMPTY_PASSWORD", "")
+	defer os.Unsetenv("TUS_TEST_EMPTY_PASSWORD")
+
+	for _, test := range []struct {
+		name            string
+		releaseManifest string
+		environments    string
+		metadataFile    string
+		failures        int
+	}{
+		{"valid", write("release.yaml", "bundles:\n  - {name: base, file: base.zip}\n"),
+			write("environments.yaml", "environments:\n"+
+				"  - {name: staging, target: 'https://vra-staging/', username: admin, passwordEnv: TUS_TEST_PASSWORD}\n"+
+				"  - {name: production, target: 'https://vra/', username: admin, passwordFile: "+filepath.Join(dir, "password")+"}\n"),
+			write("metadata.yaml", "metadata:\n  version: 1.2.0\nimport:\n  option: OVERWRITE\n"), 0},
+		{"missing bundle", write("missing.yaml", "bundles:\n  - {name: base, file: missing.zip}\n"), "", "", 1},
+		{"credential sources", "",
+			write("credentials.yaml", "environments:\n"+
+				"  - {name: unset, target: 'https://a/', username: admin, passwordEnv: TUS_TEST_UNSET_PASSWORD}\n"+
+				"  - {name: empty, target: 'https://b/', username: admin, passwordEnv: TUS_TEST_EMPTY_PASSWORD}\n"+
+				"  - {name: missing, target: 'https://c/', username: admin, passwordFile: "+filepath.Join(dir, "missing")+"}\n"+
+				"  - {name: empty-file, target: 'https://d/', username: admin, passwordFile: "+filepath.Join(dir, "empty-password")+"}\n"+
+				"  - {name: default, target: 'https://e/'}\n"),
+			"", 4},
+		{"invalid inventory", "", write("invalid.yaml", "environments:\n  - {name: staging, target: 'https://vra/', password: secret}\n"), "", 1},
+		{"invalid metadata", "", "", write("metadata.json", `{"metadata": {"a b": "c"}}`), 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			report := &doctorReport{}
+			validateConfig(report, test.releaseManifest, test.environments, test.metadataFile)
+			if report.failures != test.failures {
+				t.Errorf("%d failures instead of %d", report.failures, test.failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	netURL "net/url"
+	"strings"
+	"time"
+
+	"github.com/eventials/go-tus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	doctorTimeout = 10 * time.Second
+	// certificateExpiryWarning is how long before its expiry a certificate is reported
+	certificateExpiryWarning = 14 * 24 * time.Hour
+)
+
+func newDoctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor [target]",
+		Short: "Check the DNS, network, proxy, TLS, TUS support and login of a target",
+		Long: `Runs the checks of the environment between this host and a target and prints a checklist.
+Exits with 1 when a check fails.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: doctor,
+	}
+	cmd.Flags().String("target", "", "url to upload to")
+	addConnectionFlags(cmd)
+	return cmd
+}
+
+// doctorReport prints the checklist.
+type doctorReport struct {
+	failures int
+}
+
+func (r *doctorReport) check(status, name, format string, args ...interface{}) {
+	if status == "FAIL" {
+		r.failures++
+	}
+	fmt.Printf("[%s] %-8s %s\n", status, name, fmt.Sprintf(format, args...))
+}
+
+func doctor(cmd *cobra.Command, args []string) error {
+	target, err := cmd.Flags().GetString("target")
+	if err != nil {
+		return err
+	}
+	if target == "" && len(args) == 1 {
+		target = args[0]
+	}
+	if target == "" {
+		return fmt.Errorf("Missing the target to check")
+	}
+	opts, err := parseConnectionOptions(cmd)
+	if err != nil {
+		return err
+	}
+	report := &doctorReport{}
+
+	if isSRVTarget(target) {
+		resolved, err := resolveSRVTarget(target)
+		if err != nil {
+			report.check("FAIL", "SRV", "%s", err.Error())
+			return fmt.Errorf("1 check failed")
+		}
+		report.check("PASS", "SRV", "%s resolves to %s", target, resolved)
+		target = resolved
+	}
+	u, err := netURL.Parse(target)
+	if err != nil || !isURL(target) {
+		return fmt.Errorf("Invalid target '%s'", target)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	hostPort := net.JoinHostPort(u.Hostname(), port)
+
+	proxy, err := http.ProxyFromEnvironment(&http.Request{URL: u})
+	if err != nil {
+		report.check("FAIL", "Proxy", "Invalid proxy settings: %s", err.Error())
+	} else if proxy != nil {
+		report.check("PASS", "Proxy", "%s goes through the proxy %s", u.Hostname(), redactedURL(proxy))
+	} else {
+		report.check("PASS", "Proxy", "Direct connection to %s (no HTTP_PROXY, HTTPS_PROXY or matching NO_PROXY)", u.Hostname())
+	}
+
+	if addresses, err := net.LookupHost(u.Hostname()); err != nil {
+		status := "FAIL"
+		if proxy != nil {
+			// The proxy resolves the target
+			status = "WARN"
+		}
+		report.check(status, "DNS", "%s", err.Error())
+	} else {
+		report.check("PASS", "DNS", "%s resolves to %s", u.Hostname(), strings.Join(addresses, ", "))
+	}
+
+	dial := hostPort
+	if proxy != nil {
+		dial = proxy.Host
+		if proxy.Port() == "" {
+			port := "80"
+			if proxy.Scheme == "https" {
+				port = "443"
+			}
+			dial = net.JoinHostPort(proxy.Hostname(), port)
+		}
+	}
+	if conn, err := net.DialTimeout("tcp", dial, doctorTimeout); err != nil {
+		report.check("FAIL", "TCP", "%s", err.Error())
+	} else {
+		conn.Close()
+		report.check("PASS", "TCP", "Connected to %s", dial)
+	}
+
+	if u.Scheme == "https" {
+		if proxy != nil {
+			report.check("SKIP", "TLS", "The certificate is only checked on direct connections, see the TUS check")
+		} else {
+			checkCertificate(report, hostPort, u.Hostname(), opts)
+		}
+	}
+
+	clientConfig := tus.DefaultConfig()
+	clientConfig.Header = opts.httpHeaders
+	clientConfig.HttpClient = newHTTPClient(opts)
+	clientConfig.HttpClient.Timeout = doctorTimeout
+	client, err := tus.NewClient(target, clientConfig)
+	if err != nil {
+		return err
+	}
+	if caps, err := tusPreflight(client); err != nil {
+		report.check("FAIL", "TUS", "%s", err.Error())
+	} else if !caps.supports("creation") {
+		report.check("FAIL", "TUS", "The server does not support the creation extension (extensions: %s)", strings.Join(caps.Extensions, ", "))
+	} else {
+		details := fmt.Sprintf("TUS %s, extensions: %s", strings.Join(caps.Versions, ", "), strings.Join(caps.Extensions, ", "))
+		if caps.MaxSize > 0 {
+			details += fmt.Sprintf(", max size: %d Bytes", caps.MaxSize)
+		}
+		report.check("PASS", "TUS", "%s", details)
+	}
+
+	switch {
+	case bearerToken != "":
+		report.check("SKIP", "Login", "Using the BEARER_TOKEN of the environment")
+	case opts.vraUser != "" || opts.clientID != "":
+		if _, err := vraLogin(opts, client, clientConfig); err != nil {
+			report.check("FAIL", "Login", "%s", err.Error())
+		} else if opts.clientID != "" {
+			report.check("PASS", "Login", "Logged in as the client %s", opts.clientID)
+		} else {
+			report.check("PASS", "Login", "Logged in as %s", opts.vraUser)
+		}
+	default:
+		report.check("SKIP", "Login", "No --vra-username or --client-id")
+	}
+
+	if report.failures > 0 {
+		return fmt.Errorf("%d checks failed", report.failures)
+	}
+	return nil
+}
+
+// checkCertificate verifies the certificate chain of a host with the system roots, or with the rules of
+// --trust-fingerprint and --trust-on-first-use when they are set.
+func checkCertificate(report *doctorReport, hostPort, serverName string, opts *options) {
+	dialer := &net.Dialer{Timeout: doctorTimeout}
+	if len(opts.trustFingerprints) > 0 || opts.knownHosts != nil {
+		checkCertificateFingerprint(report, dialer, hostPort, serverName, opts)
+		return
+	}
+	conn, err := tls.DialWithDialer(dialer, "tcp", hostPort, &tls.Config{ServerName: serverName})
+	if err == nil {
+		defer conn.Close()
+		leaf := conn.ConnectionState().PeerCertificates[0]
+		if time.Until(leaf.NotAfter) < certificateExpiryWarning {
+			report.check("WARN", "TLS", "The certificate of %s expires on %s", serverName, leaf.NotAfter.Format(timestampFormat))
+		} else {
+			report.check("PASS", "TLS", "Valid certificate of %s issued by %s, expires on %s", serverName, leaf.Issuer.CommonName, leaf.NotAfter.Format(timestampFormat))
+		}
+		return
+	}
+	if opts.skipTLSVerification {
+		report.check("WARN", "TLS", "%s (ignored with --skip-ssl-verification)", err.Error())
+	} else {
+		report.check("FAIL", "TLS", "%s", err.Error())
+	}
+}
+
+// checkCertificateFingerprint checks a certificate like the uploads do with the trusted fingerprints, without asking
+// to trust a certificate seen for the first time.
+func checkCertificateFingerprint(report *doctorReport, dialer *net.Dialer, hostPort, serverName string, opts *options) {
+	conn, err := tls.DialWithDialer(dialer, "tcp", hostPort, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	if err != nil {
+		report.check("FAIL", "TLS", "%s", err.Error())
+		return
+	}
+	defer conn.Close()
+	var rawCerts [][]byte
+	for _, cert := range conn.ConnectionState().PeerCertificates {
+		rawCerts = append(rawCerts, cert.Raw)
+	}
+	trust := &certificateTrust{fingerprints: opts.trustFingerprints, knownHosts: opts.knownHosts, noPrompt: true}
+	if err := trust.verify(hostPort, serverName, rawCerts); err != nil {
+		report.check("FAIL", "TLS", "%s", err.Error())
+		return
+	}
+	leaf := conn.ConnectionState().PeerCertificates[0]
+	if time.Until(leaf.NotAfter) < certificateExpiryWarning {
+		report.check("WARN", "TLS", "The certificate of %s expires on %s", serverName, leaf.NotAfter.Format(timestampFormat))
+	} else {
+		report.check("PASS", "TLS", "Trusted certificate of %s with the SHA-256 fingerprint %s, expires on %s", serverName, formatFingerprint(certificateFingerprint(leaf.Raw)), leaf.NotAfter.Format(timestampFormat))
+	}
+}
+
+func redactedURL(u *netURL.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+	redacted := *u
+	redacted.User = netURL.User(u.User.Username())
+	return redacted.String()
+}
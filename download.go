@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newDownloadCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "download <upload-url>",
+		Short: "Fetch an uploaded file from a server that allows GET on the uploads",
+		Long: `Fetches the content of an upload to verify what the server received.
+The size and the checksum of the downloaded content are printed so that they can be compared with the source.`,
+		Args: cobra.ExactArgs(1),
+		RunE: downloadUpload,
+	}
+	addConnectionFlags(cmd)
+	cmd.Flags().StringP("output", "o", "", "File to write. '-' for stdout. Defaults to the filename metadata of the upload")
+	cmd.Flags().Bool("metadata-only", false, "Only print the offset, length and metadata of the upload")
+	cmd.Flags().String("checksum-algo", "sha256", "Checksum algorithm of the digest printed after the download: sha1, sha256, sha512 or crc32")
+	cmd.Flags().String("decrypt-key", "", "File with the key of an upload encrypted with --encrypt-key")
+	return cmd
+}
+
+func downloadUpload(cmd *cobra.Command, args []string) error {
+	uploadURL := args[0]
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	metadataOnly, err := cmd.Flags().GetBool("metadata-only")
+	if err != nil {
+		return err
+	}
+	algo, err := cmd.Flags().GetString("checksum-algo")
+	if err != nil {
+		return err
+	}
+	if _, err := newHash(algo); err != nil {
+		return err
+	}
+	decryptKeyPath, err := cmd.Flags().GetString("decrypt-key")
+	if err != nil {
+		return err
+	}
+	var decryptKey []byte
+	if decryptKeyPath != "" {
+		if decryptKey, err = loadEncryptionKey(decryptKeyPath); err != nil {
+			return err
+		}
+	}
+	client, err := connect(cmd, uploadURL)
+	if err != nil {
+		return err
+	}
+
+	info, err := headUpload(client, uploadURL)
+	if err != nil {
+		return err
+	}
+	// Keep stdout for the content when it is the output
+	log := os.Stdout
+	if output == "-" {
+		log = os.Stderr
+	}
+	fmt.Fprintf(log, "Upload:   %s\n", uploadURL)
+	if info.Length >= 0 {
+		fmt.Fprintf(log, "Received: %d Bytes of %d Bytes\n", info.Offset, info.Length)
+	} else {
+		fmt.Fprintf(log, "Received: %d Bytes, length deferred\n", info.Offset)
+	}
+	for _, k := range sortedKeys(info.Metadata) {
+		fmt.Fprintf(log, "Metadata: %s=%s\n", k, info.Metadata[k])
+	}
+	if metadataOnly {
+		return nil
+	}
+	encrypted := info.Metadata["encryption"] == encryptionName
+	if decryptKey != nil && !encrypted {
+		return fmt.Errorf("The upload %s is not encrypted", uploadURL)
+	}
+
+	if output == "" {
+		output = filepath.Base(info.Metadata["filename"])
+		if decryptKey != nil {
+			output = strings.TrimSuffix(output, ".enc")
+		}
+		if output == "." || output == string(filepath.Separator) {
+			output = bundleIDFromURL(uploadURL)
+		}
+	}
+
+	req, err := http.NewRequest("GET", uploadURL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return fmt.Errorf("The server does not allow to download %s: %s", uploadURL, res.Status)
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	// The digest is the one of the content written so that it matches the source file once decrypted
+	h, _ := newHash(algo)
+	w = io.MultiWriter(w, h)
+	counter := &countingWriter{}
+	body := io.TeeReader(res.Body, counter)
+	if decryptKey != nil {
+		err = decryptStream(decryptKey, body, w)
+	} else {
+		_, err = io.Copy(w, body)
+	}
+	if err != nil {
+		if output != "-" {
+			os.Remove(output)
+		}
+		return err
+	}
+	if counter.n != info.Offset {
+		return fmt.Errorf("Downloaded %d Bytes but the server reports an offset of %d Bytes", counter.n, info.Offset)
+	}
+	if output != "-" {
+		fmt.Fprintf(log, "Saved:    %s\n", output)
+	}
+	fmt.Fprintf(log, "Digest:   %s:%x\n", algo, h.Sum(nil))
+	return nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
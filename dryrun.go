@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/eventials/go-tus"
+)
+
+// dryRun reports what an upload would do without transferring any data.
+func dryRun(f *os.File, client *tus.Client, opts *options) error {
+	httpHeaders := client.Config.Header
+	vraImport := opts.vraImport && clientToken(client) != ""
+	upload, err := newFileUpload(f, nil, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Dry run: nothing will be uploaded\n")
+	fmt.Printf("  Source:     %s (%d Bytes)\n", f.Name(), upload.Size())
+	fmt.Printf("  Target:     %s\n", client.Url)
+	chunks := (upload.Size() + client.Config.ChunkSize - 1) / client.Config.ChunkSize
+	fmt.Printf("  Chunks:     %d of %d Bytes\n", chunks, client.Config.ChunkSize)
+	if client.Config.OverridePatchMethod {
+		fmt.Printf("  Method:     POST with X-HTTP-Method-Override: PATCH\n")
+	}
+
+	for _, k := range sortedKeys(upload.Metadata) {
+		fmt.Printf("  Metadata:   %s=%s\n", k, upload.Metadata[k])
+	}
+	for _, name := range sortedHeaderNames(httpHeaders) {
+		value := "<redacted>"
+		if !strings.EqualFold(name, "Authorization") && !containsFold(opts.secretHeaders, name) {
+			value = httpHeaders.Get(name)
+		}
+		fmt.Printf("  Header:     %s: %s\n", name, value)
+	}
+	for _, header := range opts.headerTemplates {
+		fmt.Printf("  Header:     %s: %s (evaluated for every request)\n", header.name, header.source)
+	}
+
+	caps, err := tusPreflight(client)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("  Server:     TUS %s (extensions: %s)\n", strings.Join(caps.Versions, ", "), strings.Join(caps.Extensions, ", "))
+	if caps.MaxSize > 0 {
+		if upload.Size() > caps.MaxSize {
+			return fmt.Errorf("%s is %d Bytes which is larger than the Tus-Max-Size of %d Bytes", f.Name(), upload.Size(), caps.MaxSize)
+		}
+		fmt.Printf("  Max size:   %d Bytes\n", caps.MaxSize)
+	}
+	if opts.maxSize > 0 {
+		if upload.Size() > opts.maxSize {
+			return fmt.Errorf("%s is %d Bytes which is larger than the --max-size of %d Bytes", f.Name(), upload.Size(), opts.maxSize)
+		}
+		fmt.Printf("  Max size:   %d Bytes (--max-size)\n", opts.maxSize)
+	}
+
+	if vraImport {
+		entries, err := validateBundle(f.Name())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  Bundle:     valid zip archive with %d entries\n", entries)
+		payload, err := json.Marshal(vraImportPayload("(assigned by the server)", opts.importExtras))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  Import:     POST %s %s\n", client.Url, payload)
+	}
+	return nil
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
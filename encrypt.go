@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The encrypted stream is a header followed by records of up to encryptionRecordSize Bytes of plaintext,
+// each sealed with AES-256-GCM. The nonce of a record is the base nonce xor-ed with the record counter
+// and the last record is authenticated as such so that a truncated stream fails to decrypt.
+const (
+	encryptionMagic      = "TUSAES01"
+	encryptionName       = "aes-256-gcm"
+	encryptionRecordSize = 64 * 1024
+)
+
+// loadEncryptionKey reads a 32 Bytes key stored raw, hex or base64 encoded.
+func loadEncryptionKey(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == 32 {
+		return content, nil
+	}
+	text := strings.TrimSpace(string(content))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("Invalid encryption key %s: it must contain 32 Bytes, raw, hex or base64 encoded", path)
+}
+
+func recordNonce(base []byte, counter uint64) []byte {
+	nonce := make([]byte, len(base))
+	copy(nonce, base)
+	var c [8]byte
+	binary.BigEndian.PutUint64(c[:], counter)
+	for i := range c {
+		nonce[len(nonce)-8+i] ^= c[i]
+	}
+	return nonce
+}
+
+func recordAdditionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// encryptStream encrypts r into w.
+func encryptStream(key []byte, r io.Reader, w io.Writer) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	baseNonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(baseNonce); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, encryptionMagic); err != nil {
+		return err
+	}
+	if _, err := w.Write(baseNonce); err != nil {
+		return err
+	}
+
+	// Read one record ahead to know which one is the last
+	current := make([]byte, encryptionRecordSize)
+	next := make([]byte, encryptionRecordSize)
+	n, err := io.ReadFull(r, current)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	for counter := uint64(0); ; counter++ {
+		m := 0
+		if n == encryptionRecordSize {
+			m, err = io.ReadFull(r, next)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return err
+			}
+		}
+		last := m == 0
+		sealed := aead.Seal(nil, recordNonce(baseNonce, counter), current[:n], recordAdditionalData(last))
+		if _, err := w.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		current, next = next, current
+		n = m
+	}
+}
+
+// encryptedSize is the size of the stream encrypting size Bytes.
+func encryptedSize(size int64) int64 {
+	// a stream of a multiple of the record size has no empty last record, only the empty stream has one
+	records := (size + encryptionRecordSize - 1) / encryptionRecordSize
+	if records == 0 {
+		records = 1
+	}
+	return int64(len(encryptionMagic)) + 12 + size + records*16
+}
+
+// encryptToTempFile stages the encrypted content of a file in the temp directory.
+// The caller removes the returned file.
+func encryptToTempFile(key []byte, file string) (string, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+	if err := checkStagingSpace(os.TempDir(), encryptedSize(fi.Size())); err != nil {
+		return "", err
+	}
+	out, err := ioutil.TempFile("", "tus-uploader-*-"+filepath.Base(file)+".enc")
+	if err != nil {
+		return "", err
+	}
+	if err := encryptStream(key, in, out); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// decryptStream decrypts a stream written by encryptStream into w.
+func decryptStream(key []byte, r io.Reader, w io.Writer) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, len(encryptionMagic)+aead.NonceSize())
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(encryptionMagic)]) != encryptionMagic {
+		return fmt.Errorf("The content is not encrypted with %s", encryptionName)
+	}
+	baseNonce := header[len(encryptionMagic):]
+
+	// Read one record ahead to know which one is the last
+	recordSize := encryptionRecordSize + aead.Overhead()
+	current := make([]byte, recordSize)
+	next := make([]byte, recordSize)
+	n, err := io.ReadFull(r, current)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	for counter := uint64(0); ; counter++ {
+		m := 0
+		if n == recordSize {
+			m, err = io.ReadFull(r, next)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return err
+			}
+		}
+		last := m == 0
+		plain, err := aead.Open(nil, recordNonce(baseNonce, counter), current[:n], recordAdditionalData(last))
+		if err != nil {
+			return fmt.Errorf("Unable to decrypt the content: wrong key, corrupted or truncated data")
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		current, next = next, current
+		n = m
+	}
+}
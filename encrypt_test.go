@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encryptBytes(t *testing.T, key, plain []byte) []byte {
+	var encrypted bytes.Buffer
+	if err := encryptStream(key, bytes.NewReader(plain), &encrypted); err != nil {
+		t.Fatalf("encryptStream: %v", err)
+	}
+	return encrypted.Bytes()
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	key := testKey(t)
+	for _, size := range []int{0, 1, encryptionRecordSize - 1, encryptionRecordSize, encryptionRecordSize + 1, 3*encryptionRecordSize + 17} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		encrypted := encryptBytes(t, key, plain)
+		if int64(len(encrypted)) != encryptedSize(int64(size)) {
+			t.Errorf("size %d: encrypted to %d Bytes, encryptedSize says %d", size, len(encrypted), encryptedSize(int64(size)))
+		}
+		var decrypted bytes.Buffer
+		if err := decryptStream(key, bytes.NewReader(encrypted), &decrypted); err != nil {
+			t.Fatalf("size %d: decryptStream: %v", size, err)
+		}
+		if !bytes.Equal(decrypted.Bytes(), plain) {
+			t.Errorf("size %d: the decrypted content differs", size)
+		}
+	}
+}
+
+func TestDecryptTruncated(t *testing.T) {
+	key := testKey(t)
+	plain := make([]byte, 2*encryptionRecordSize+100)
+	rand.Read(plain)
+	encrypted := encryptBytes(t, key, plain)
+	header := len(encryptionMagic) + 12
+	record := encryptionRecordSize + 16
+	for name, length := range map[string]int{
+		"header only":          header,
+		"at a record boundary": header + record,
+		"within a record":      header + record + 10,
+		"last byte missing":    len(encrypted) - 1,
+	} {
+		if err := decryptStream(key, bytes.NewReader(encrypted[:length]), &bytes.Buffer{}); err == nil {
+			t.Errorf("%s: decrypted a truncated stream", name)
+		}
+	}
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	encrypted := encryptBytes(t, testKey(t), []byte("secret bundle"))
+	if err := decryptStream(testKey(t), bytes.NewReader(encrypted), &bytes.Buffer{}); err == nil {
+		t.Error("decrypted with the wrong key")
+	}
+}
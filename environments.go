@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// environment is a vRA of an --environments inventory. The password is read from an environment variable or a file
+// and defaults to the --vra-username and --vra-password. eg:
+//
+//	environments:
+//	  - name: staging
+//	    target: https://vra-staging/provisioning/ipam/api/providers/packages/import
+//	    username: admin
+//	    passwordEnv: VRA_STAGING_PASSWORD
+//	  - name: production
+//	    target: https://vra/provisioning/ipam/api/providers/packages/import
+//	    username: admin
+//	    passwordFile: /run/secrets/vra-production
+type environment struct {
+	Name         string `json:"name" yaml:"name"`
+	Target       string `json:"target" yaml:"target"`
+	Username     string `json:"username" yaml:"username"`
+	PasswordEnv  string `json:"passwordEnv" yaml:"passwordEnv"`
+	PasswordFile string `json:"passwordFile" yaml:"passwordFile"`
+}
+
+// loadEnvironments loads an --environments inventory. The JSON inventories are parsed as YAML.
+func loadEnvironments(path string) ([]environment, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := struct {
+		Environments []environment `yaml:"environments"`
+	}{}
+	if err := yaml.UnmarshalStrict(content, &raw); err != nil {
+		return nil, fmt.Errorf("Invalid environments inventory %s: %s", path, err.Error())
+	}
+	if len(raw.Environments) == 0 {
+		return nil, fmt.Errorf("Invalid environments inventory %s: no environments", path)
+	}
+	names := make(map[string]bool, len(raw.Environments))
+	for i, env := range raw.Environments {
+		if env.Name == "" {
+			return nil, fmt.Errorf("Invalid environments inventory %s: the environment %d must have a name", path, i+1)
+		}
+		if names[env.Name] {
+			return nil, fmt.Errorf("Invalid environments inventory %s: the environment '%s' is declared twice", path, env.Name)
+		}
+		names[env.Name] = true
+		if !isURL(env.Target) && !isSRVTarget(env.Target) {
+			return nil, fmt.Errorf("Invalid environments inventory %s: invalid target '%s' of the environment '%s'", path, env.Target, env.Name)
+		}
+		if env.PasswordEnv != "" && env.PasswordFile != "" {
+			return nil, fmt.Errorf("Invalid environments inventory %s: the environment '%s' has both a passwordEnv and a passwordFile", path, env.Name)
+		}
+	}
+	return raw.Environments, nil
+}
+
+// environmentOptions are the options of the login to env.
+func environmentOptions(env environment, opts *options) (*options, error) {
+	envOpts := *opts
+	if env.Username != "" {
+		envOpts.vraUser = env.Username
+	}
+	switch {
+	case env.PasswordEnv != "":
+		password, ok := os.LookupEnv(env.PasswordEnv)
+		if !ok {
+			return nil, fmt.Errorf("The environment variable %s of the password of the environment '%s' is not set", env.PasswordEnv, env.Name)
+		}
+		envOpts.vraPassword = password
+	case env.PasswordFile != "":
+		content, err := ioutil.ReadFile(env.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the password of the environment '%s': %s", env.Name, err.Error())
+		}
+		envOpts.vraPassword = strings.TrimRight(string(content), "\r\n")
+	}
+	return &envOpts, nil
+}
+
+// printEnvironmentsSummary reports the outcome of the uploads of each environment.
+func printEnvironmentsSummary(envs []environment, results []*uploadResult) {
+	console.Printf("Environments:\n")
+	for _, env := range envs {
+		total, failed := 0, 0
+		var errors []string
+		for _, result := range results {
+			if result.Environment != env.Name {
+				continue
+			}
+			total++
+			if result.Error != "" {
+				failed++
+				errors = append(errors, result.File+": "+result.Error)
+			}
+		}
+		switch {
+		case total == 0:
+			console.Printf("  %s: not started\n", env.Name)
+		case failed == 0:
+			console.Printf("  %s: succeeded (%d files)\n", env.Name, total)
+		default:
+			console.Printf("  %s: failed (%d of %d files)\n", env.Name, failed, total)
+			for _, err := range errors {
+				console.Printf("    %s\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/eventials/go-tus"
+)
+
+const probeSize = 1024 * 1024
+
+// estimate reports the size, digest, chunk count and ETA of an upload without performing it.
+// The bandwidth is measured by uploading a small throw-away probe to the target, only when the server supports the
+// termination extension to delete it.
+func estimate(f *os.File, client *tus.Client, algo string) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := fi.Size()
+	chunkSize := client.Config.ChunkSize
+	chunks := (size + chunkSize - 1) / chunkSize
+
+	fmt.Printf("Estimate: nothing will be uploaded\n")
+	fmt.Printf("  Source:     %s\n", f.Name())
+	fmt.Printf("  Size:       %d Bytes\n", size)
+	digest, err := fileDigest(f.Name(), algo)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("  %-11s %s\n", algo+":", digest)
+	fmt.Printf("  Chunks:     %d of %d Bytes\n", chunks, chunkSize)
+
+	start := time.Now()
+	caps, err := tusPreflight(client)
+	if err != nil {
+		return err
+	}
+	latency := time.Since(start)
+	fmt.Printf("  Latency:    %v\n", latency.Round(time.Millisecond))
+
+	if !caps.supports("termination") {
+		fmt.Printf("  Bandwidth:  not measured, %s does not support the termination extension to delete the probe\n", client.Url)
+		return nil
+	}
+	bandwidth, err := probeBandwidth(client)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("  Bandwidth:  %.2f MB/s\n", bandwidth/1000/1000)
+	eta := time.Duration(float64(size)/bandwidth*float64(time.Second)) + time.Duration(chunks)*latency
+	fmt.Printf("  ETA:        %v\n", eta.Round(time.Second))
+	return nil
+}
+
+// probeBandwidth uploads probeSize Bytes and returns the measured throughput in Bytes per second.
+func probeBandwidth(client *tus.Client) (float64, error) {
+	probe := tus.NewUploadFromBytes(make([]byte, probeSize))
+	probe.Metadata["filename"] = "tus-uploader-probe"
+	uploader, err := client.CreateUpload(probe)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to create the bandwidth probe: %s", err.Error())
+	}
+	start := time.Now()
+	if err := uploader.Upload(); err != nil {
+		return 0, fmt.Errorf("Failed to upload the bandwidth probe: %s", err.Error())
+	}
+	elapsed := time.Since(start)
+
+	if err := deleteUpload(client, uploader.Url()); err != nil {
+		console.Printf("Warning: unable to delete the bandwidth probe %s: %s\n", uploader.Url(), err.Error())
+	}
+	return float64(probeSize) / elapsed.Seconds(), nil
+}
+
+// deleteUpload terminates an upload (termination extension).
+func deleteUpload(client *tus.Client, uploadURL string) error {
+	req, err := http.NewRequest("DELETE", uploadURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Length", "0")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 204 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/eventials/go-tus"
+)
+
+const (
+	followPollInterval   = time.Second
+	followReportInterval = 10 * time.Second
+)
+
+// followFile uploads a file while it is still written, with a deferred length (creation-defer-length extension).
+// The chunks are sent as soon as they are complete and the upload ends once the file did not grow for opts.followIdle.
+func followFile(f *os.File, client *tus.Client, opts *options, result *uploadResult) (*tus.Uploader, error) {
+	caps, err := tusPreflight(client)
+	if err != nil {
+		return nil, err
+	}
+	if !caps.supports("creation-defer-length") {
+		return nil, fmt.Errorf("%s does not support the creation-defer-length extension required by --follow", client.Url)
+	}
+	upload, err := newFileUpload(f, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	uploadURL, err := createDeferredUpload(client, upload)
+	if err != nil {
+		return nil, err
+	}
+	result.UploadURL = uploadURL
+	result.BundleID = bundleIDFromURL(uploadURL)
+	console.Printf("%s Starting the upload to %s, following %s until it does not grow for %s\n", time.Now().Format(timestampFormat), uploadURL, f.Name(), opts.followIdle)
+
+	const attempts = 50
+	var offset int64
+	attempt := 1
+	buf := make([]byte, client.Config.ChunkSize)
+	lastSize, lastGrowth, lastReport := int64(-1), time.Now(), time.Now()
+	for {
+		if isInterrupted() {
+			return nil, errInterrupted
+		}
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		done := false
+		if n < len(buf) {
+			fi, err := f.Stat()
+			if err != nil {
+				return nil, err
+			}
+			if fi.Size() != lastSize {
+				lastSize, lastGrowth = fi.Size(), time.Now()
+			}
+			done = time.Since(lastGrowth) >= opts.followIdle
+			if !done {
+				if err := sleep(followPollInterval); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		if limit := followMaxSize(opts.maxSize, caps.MaxSize); limit > 0 && offset+int64(n) > limit {
+			return nil, fmt.Errorf("%s grew past the maximum size of %d Bytes", f.Name(), limit)
+		}
+		length := int64(-1)
+		if done {
+			length = offset + int64(n)
+		}
+		newOffset, err := patchChunk(client, uploadURL, offset, buf[:n], length)
+		if err != nil {
+			if attempt >= attempts {
+				return nil, err
+			}
+			attempt++
+			result.Attempts = attempt
+			console.Printf("Error %v\nTrying again in 10 seconds\n", err)
+			if err := sleep(time.Second * 10); err != nil {
+				return nil, err
+			}
+			console.Retry(f.Name(), attempt, attempts)
+			info, err := headUpload(client, uploadURL)
+			if err != nil {
+				return nil, err
+			}
+			offset = info.Offset
+			continue
+		}
+		offset = newOffset
+		if done {
+			break
+		}
+		if time.Since(lastReport) >= followReportInterval {
+			lastReport = time.Now()
+			console.Printf("%s Uploaded %d Bytes of %s, still growing\n", time.Now().Format(timestampFormat), offset, f.Name())
+		}
+	}
+	result.Size = offset
+	console.Printf("%s %s did not grow for %s: uploaded %d Bytes\n", time.Now().Format(timestampFormat), f.Name(), opts.followIdle, offset)
+	return tus.NewUploader(client, uploadURL, upload, offset), nil
+}
+
+// createDeferredUpload creates an upload whose length is sent with its last chunk.
+func createDeferredUpload(client *tus.Client, upload *tus.Upload) (string, error) {
+	req, err := http.NewRequest("POST", client.Url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Length", "0")
+	req.Header.Set("Upload-Defer-Length", "1")
+	req.Header.Set("Upload-Metadata", upload.EncodedMetadata())
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 201 {
+		return "", fmt.Errorf("Failed to create the upload on %s: %s", client.Url, res.Status)
+	}
+	location, err := req.URL.Parse(res.Header.Get("Location"))
+	if err != nil {
+		return "", err
+	}
+	return location.String(), nil
+}
+
+// patchChunk sends a chunk at offset, with the Upload-Length of the upload when it is known.
+func patchChunk(client *tus.Client, uploadURL string, offset int64, chunk []byte, length int64) (int64, error) {
+	method := "PATCH"
+	if client.Config.OverridePatchMethod {
+		method = "POST"
+	}
+	req, err := http.NewRequest(method, uploadURL, bytes.NewReader(chunk))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	if length >= 0 {
+		req.Header.Set("Upload-Length", strconv.FormatInt(length, 10))
+	}
+	if client.Config.OverridePatchMethod {
+		req.Header.Set("X-HTTP-Method-Override", "PATCH")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 204 {
+		return 0, fmt.Errorf("Failed to upload the chunk at %d Bytes to %s: %s", offset, uploadURL, res.Status)
+	}
+	newOffset, err := strconv.ParseInt(res.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid Upload-Offset '%s' returned by %s", res.Header.Get("Upload-Offset"), uploadURL)
+	}
+	return newOffset, nil
+}
+
+// followMaxSize is the smallest of --max-size and Tus-Max-Size, 0 when there is no limit.
+func followMaxSize(maxSize, serverMaxSize int64) int64 {
+	if maxSize == 0 || (serverMaxSize > 0 && serverMaxSize < maxSize) {
+		return serverMaxSize
+	}
+	return maxSize
+}
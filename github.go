@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/eventials/go-tus"
+)
+
+// githubActions tells whether the uploader runs in a GitHub Actions workflow.
+func githubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// escapeWorkflowCommand escapes the data of a GitHub Actions workflow command.
+func escapeWorkflowCommand(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// githubReporter collapses the retries in log groups and annotates the failures.
+type githubReporter struct {
+	*plainReporter
+	groups sync.Map
+	// annotated is set once a failed upload was annotated
+	annotated int32
+}
+
+// command writes a workflow command on stdout, where GitHub Actions reads them, also when the logs go to stderr.
+func (r *githubReporter) command(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(os.Stdout, format, args...)
+}
+
+func (r *githubReporter) Retry(name string, attempt, attempts int) {
+	if _, open := r.groups.LoadOrStore(name, true); !open {
+		r.command("::group::Retrying the upload of %s\n", escapeWorkflowCommand(name))
+	}
+	r.plainReporter.Retry(name, attempt, attempts)
+}
+
+func (r *githubReporter) endGroup(name string) {
+	if _, open := r.groups.Load(name); open {
+		r.groups.Delete(name)
+		r.command("::endgroup::\n")
+	}
+}
+
+func (r *githubReporter) Progress(name string, upload tus.Upload) {
+	r.endGroup(name)
+	r.plainReporter.Progress(name, upload)
+}
+
+func (r *githubReporter) Finished(name string, err error) {
+	r.endGroup(name)
+	if err != nil {
+		atomic.StoreInt32(&r.annotated, 1)
+		r.command("::error title=Upload failed::%s\n", escapeWorkflowCommand(name+": "+err.Error()))
+	}
+}
+
+// annotatedFailure tells whether the console already annotated a failed upload, so that the error of the run is not
+// annotated a second time.
+func annotatedFailure() bool {
+	r, ok := console.(*githubReporter)
+	return ok && atomic.LoadInt32(&r.annotated) == 1
+}
+
+// writeGitHubOutputs sets the step outputs of the last successful upload and the count of uploads and failures.
+func writeGitHubOutputs(results []*uploadResult) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		return nil
+	}
+	var b strings.Builder
+	failed := 0
+	var last *uploadResult
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		} else if result.Status == "uploaded" {
+			last = result
+		}
+	}
+	if last != nil {
+		fmt.Fprintf(&b, "upload-url=%s\n", last.UploadURL)
+		fmt.Fprintf(&b, "bundle-id=%s\n", last.BundleID)
+		fmt.Fprintf(&b, "provider-name=%s\n", last.ProviderName)
+		fmt.Fprintf(&b, "provider-version=%s\n", last.ProviderVersion)
+		fmt.Fprintf(&b, "integrations-url=%s\n", last.IntegrationsURL)
+	}
+	fmt.Fprintf(&b, "uploads=%d\n", len(results))
+	fmt.Fprintf(&b, "failures=%d\n", failed)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(b.String())
+	return err
+}
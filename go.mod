@@ -7,4 +7,5 @@ replace github.com/eventials/go-tus => github.com/hmalphettes/go-tus v0.0.0-2020
 require (
 	github.com/eventials/go-tus v0.0.0-20200718001131-45c7ec8f5d59
 	github.com/spf13/cobra v1.0.0
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// parseHeaders parses "Name: value" flags.
+// A header may be repeated and a value starting with @ is read from a file. eg: "X-Token: @/path/to/file"
+func parseHeaders(headers []string) (http.Header, error) {
+	httpHeaders := make(http.Header)
+	for _, header := range headers {
+		toks := strings.SplitN(header, ":", 2)
+		name := strings.TrimSpace(toks[0])
+		if len(toks) != 2 || name == "" {
+			return nil, fmt.Errorf("Invalid header value '%s'. It must have a header-name:value separated by a colon", header)
+		}
+		value := strings.TrimSpace(toks[1])
+		if strings.HasPrefix(value, "@") {
+			content, err := ioutil.ReadFile(value[1:])
+			if err != nil {
+				return nil, fmt.Errorf("Unable to read the value of the header '%s': %s", name, err.Error())
+			}
+			value = strings.TrimRight(string(content), "\r\n")
+		}
+		httpHeaders.Add(name, value)
+	}
+	return httpHeaders, nil
+}
+
+// addHeadersFromEnv adds the "Name=ENV_VAR" flags of --header-from-env to httpHeaders.
+// It returns the names of the headers so that their values are never printed.
+func addHeadersFromEnv(httpHeaders http.Header, specs []string) ([]string, error) {
+	var names []string
+	for _, spec := range specs {
+		toks := strings.SplitN(spec, "=", 2)
+		name := strings.TrimSpace(toks[0])
+		if len(toks) != 2 || name == "" || strings.TrimSpace(toks[1]) == "" {
+			return nil, fmt.Errorf("Invalid --header-from-env '%s'. It must have a header-name=ENV_VAR separated by an equal sign", spec)
+		}
+		variable := strings.TrimSpace(toks[1])
+		value, ok := os.LookupEnv(variable)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("The environment variable %s of the header '%s' is not set", variable, name)
+		}
+		httpHeaders.Add(name, value)
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func sortedHeaderNames(headers http.Header) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
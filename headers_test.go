@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "headers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("secret:value\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		headers []string
+		want    http.Header
+		err     bool
+	}{
+		{"colon in the value", []string{"Authorization: Bearer a:b"}, http.Header{"Authorization": {"Bearer a:b"}}, false},
+		{"url value", []string{"X-Origin: https://example.com:8443/path"}, http.Header{"X-Origin": {"https://example.com:8443/path"}}, false},
+		{"repeated header", []string{"X-A: 1", "X-A: 2"}, http.Header{"X-A": {"1", "2"}}, false},
+		{"canonical name", []string{"x-a: 1", "X-A: 2"}, http.Header{"X-A": {"1", "2"}}, false},
+		{"spaces", []string{"  X-A  :  1  "}, http.Header{"X-A": {"1"}}, false},
+		{"empty value", []string{"X-A:"}, http.Header{"X-A": {""}}, false},
+		{"value from a file", []string{"X-Token: @" + tokenFile}, http.Header{"X-Token": {"secret:value"}}, false},
+		{"missing file", []string{"X-Token: @" + filepath.Join(dir, "missing")}, nil, true},
+		{"no colon", []string{"X-A 1"}, nil, true},
+		{"no name", []string{": 1"}, nil, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseHeaders(test.headers)
+			if test.err {
+				if err == nil {
+					t.Fatalf("no error for %q", test.headers)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("%v instead of %v", got, test.want)
+			}
+		})
+	}
+}
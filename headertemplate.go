@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// headerTemplate is a header whose value is a text/template evaluated for every request.
+// eg: 'X-Signature: {{hmac "sha256" (env "GATEWAY_SECRET") .Path}}'
+type headerTemplate struct {
+	name   string
+	source string
+	tmpl   *template.Template
+}
+
+// headerTemplateData is the data a header template is evaluated with.
+type headerTemplateData struct {
+	Method string
+	URL    string
+	Path   string
+	// Counter is the number of the request, starting at 1
+	Counter uint64
+	Time    time.Time
+	// Timestamp is Time in seconds since the epoch
+	Timestamp int64
+}
+
+var headerTemplateFuncs = template.FuncMap{
+	"hmac": func(algo, key, message string) (string, error) {
+		if _, err := newHash(algo); err != nil {
+			return "", err
+		}
+		mac := hmac.New(func() hash.Hash { h, _ := newHash(algo); return h }, []byte(key))
+		mac.Write([]byte(message))
+		return fmt.Sprintf("%x", mac.Sum(nil)), nil
+	},
+	"base64": func(s string) string { return base64Encode([]byte(s)) },
+	"env":    os.Getenv,
+}
+
+// splitHeaderTemplates removes the headers whose value is a template and compiles them.
+func splitHeaderTemplates(headers http.Header) ([]*headerTemplate, error) {
+	var templates []*headerTemplate
+	for name, values := range headers {
+		var static []string
+		for _, value := range values {
+			if !strings.Contains(value, "{{") {
+				static = append(static, value)
+				continue
+			}
+			tmpl, err := template.New(name).Funcs(headerTemplateFuncs).Option("missingkey=error").Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid template of the header '%s': %s", name, err.Error())
+			}
+			// Report the unknown fields and the invalid arguments now rather than on every request
+			if err := tmpl.Execute(ioutil.Discard, headerTemplateData{Time: time.Now()}); err != nil {
+				return nil, fmt.Errorf("Invalid template of the header '%s': %s", name, err.Error())
+			}
+			templates = append(templates, &headerTemplate{name: name, source: value, tmpl: tmpl})
+		}
+		if len(static) == 0 {
+			delete(headers, name)
+		} else {
+			headers[name] = static
+		}
+	}
+	return templates, nil
+}
+
+// headerTemplater sets the templated headers of the requests.
+type headerTemplater struct {
+	templates []*headerTemplate
+	counter   uint64
+}
+
+func (t *headerTemplater) apply(request *http.Request) error {
+	now := time.Now()
+	data := headerTemplateData{
+		Method:    request.Method,
+		URL:       request.URL.String(),
+		Path:      request.URL.Path,
+		Counter:   atomic.AddUint64(&t.counter, 1),
+		Time:      now,
+		Timestamp: now.Unix(),
+	}
+	for _, header := range t.templates {
+		var value bytes.Buffer
+		if err := header.tmpl.Execute(&value, data); err != nil {
+			return fmt.Errorf("Unable to evaluate the header '%s': %s", header.name, err.Error())
+		}
+		request.Header.Add(header.name, value.String())
+	}
+	return nil
+}
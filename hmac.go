@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// hmacSigner signs every request for the gateways authenticating the clients with a shared secret.
+// The signature is the base64 HMAC-SHA256 of the method, the path and query, the Date header and
+// the hex SHA-256 of the body separated by new lines.
+type hmacSigner struct {
+	key    []byte
+	header string
+}
+
+// loadHMACKey returns the secret of --hmac-key, read from a file when it starts with @. nil when there is none.
+func loadHMACKey(value string) ([]byte, error) {
+	if strings.HasPrefix(value, "@") {
+		content, err := ioutil.ReadFile(value[1:])
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the --hmac-key: %s", err.Error())
+		}
+		value = strings.TrimRight(string(content), "\r\n")
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return []byte(value), nil
+}
+
+func (s *hmacSigner) sign(request *http.Request) error {
+	var body []byte
+	if request.Body != nil {
+		var err error
+		if body, err = readBody(request); err != nil {
+			return err
+		}
+	}
+	date := time.Now().UTC().Format(http.TimeFormat)
+	request.Header.Set("Date", date)
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(request.Method + "\n" + request.URL.RequestURI() + "\n" + date + "\n" + hex.EncodeToString(bodyHash[:])))
+	request.Header.Set(s.header, base64Encode(mac.Sum(nil)))
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// journalEntry records the last successful upload of a file to a target.
+type journalEntry struct {
+	File       string    `json:"file"`
+	Target     string    `json:"target"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"modTime"`
+	Checksum   string    `json:"checksum,omitempty"`
+	UploadURL  string    `json:"uploadUrl"`
+	UploadedAt time.Time `json:"uploadedAt"`
+}
+
+// journal is a JSON file of the successful uploads, keyed by file and target.
+type journal struct {
+	path string
+	mu   sync.Mutex
+}
+
+func defaultJournalPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "tus-uploader", "journal.json")
+}
+
+func openJournal(path string) *journal {
+	if path == "" {
+		return nil
+	}
+	return &journal{path: path}
+}
+
+func journalKey(file, target string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	return abs + " " + target, nil
+}
+
+func (j *journal) load() (map[string]journalEntry, error) {
+	entries := make(map[string]journalEntry)
+	content, err := ioutil.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Get returns the last successful upload of the file to the target.
+func (j *journal) Get(file, target string) (journalEntry, bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	key, err := journalKey(file, target)
+	if err != nil {
+		return journalEntry{}, false, err
+	}
+	entries, err := j.load()
+	if err != nil {
+		return journalEntry{}, false, err
+	}
+	entry, ok := entries[key]
+	return entry, ok, nil
+}
+
+// Record stores a successful upload.
+func (j *journal) Record(entry journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	key, err := journalKey(entry.File, entry.Target)
+	if err != nil {
+		return err
+	}
+	entries, err := j.load()
+	if err != nil {
+		return err
+	}
+	entries[key] = entry
+	return writeJSONFile(j.path, entries)
+}
+
+// unchangedSince tells whether a file is the same as when it was journaled.
+// mode is either "mtime" to compare the modification time and size or "checksum" to compare the digest.
+func unchangedSince(entry journalEntry, fi os.FileInfo, file, mode, algo string) (bool, error) {
+	if fi.Size() != entry.Size {
+		return false, nil
+	}
+	if mode == "checksum" {
+		if entry.Checksum == "" {
+			return false, nil
+		}
+		digest, err := fileDigest(file, algo)
+		if err != nil {
+			return false, err
+		}
+		return algo+":"+digest == entry.Checksum, nil
+	}
+	return fi.ModTime().Equal(entry.ModTime), nil
+}
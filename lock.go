@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// uploadLock is a lock file held for the duration of the upload of a file to a target.
+type uploadLock struct {
+	path string
+}
+
+func lockPath(file, target string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(abs + "\n" + target))
+	return filepath.Join(os.TempDir(), "tus-uploader-"+hex.EncodeToString(digest[:8])+".lock"), nil
+}
+
+// acquireUploadLock fails when another process is already uploading the same file to the same target.
+func acquireUploadLock(file, target string) (*uploadLock, error) {
+	path, err := lockPath(file, target)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			owner, _ := ioutil.ReadFile(path)
+			return nil, fmt.Errorf("%s is already being uploaded to %s (%s). Remove %s if that upload is no longer running",
+				file, target, strings.TrimSpace(string(owner)), path)
+		}
+		return nil, err
+	}
+	defer f.Close()
+	host, _ := os.Hostname()
+	if _, err := fmt.Fprintf(f, "pid %d on %s since %s\n", os.Getpid(), host, time.Now().Format(timestampFormat)); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return &uploadLock{path: path}, nil
+}
+
+func (l *uploadLock) Release() {
+	os.Remove(l.path)
+}
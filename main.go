@@ -2,14 +2,17 @@ package main
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	netURL "net/url"
 	"os"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/eventials/go-tus"
@@ -24,31 +27,168 @@ var (
 	vraPassword = os.Getenv("VRA_PASSWORD")
 )
 
+// options are the command line flags shared by all the uploads of a run.
+type options struct {
+	httpHeaders     http.Header
+	headerTemplates []*headerTemplate
+	// secretHeaders are the names of the --header-from-env headers, never printed
+	secretHeaders       []string
+	methodOverride      bool
+	hmacKey             []byte
+	clientID            string
+	tokenURL            string
+	assertionKey        crypto.Signer
+	clientCertificate   *tls.Certificate
+	hmacHeader          string
+	skipOffsetCheck     bool
+	rateSchedule        []rateWindow
+	onChange            string
+	skipTLSVerification bool
+	// trustFingerprints are the normalized --trust-fingerprint
+	trustFingerprints []string
+	// knownHosts is set with --trust-on-first-use
+	knownHosts       *knownHosts
+	userAgent        string
+	vraUser          string
+	vraPassword      string
+	vraImport        bool
+	progressInterval time.Duration
+	progressPercent  int64
+	checksumAlgo     string
+	checksumSidecar  bool
+	uploadManifest   bool
+	uploadChecksum   bool
+	dryRun           bool
+	estimate         bool
+	journalPath      string
+	journal          *journal
+	resumeStore      *resumeStore
+	resumeOffset     int64
+	yes              bool
+	follow           bool
+	followIdle       time.Duration
+	// startAt is zero when there is no --start-at
+	startAt time.Time
+	// maxSize is 0 when there is no --max-size
+	maxSize         int64
+	ifChanged       string
+	noLock          bool
+	tui             bool
+	logFormat       string
+	ciFormat        string
+	progress        string
+	continueOnError bool
+	parallel        int
+	maxPerHost      int
+	batchReport     string
+	outputTemplate  *template.Template
+	releaseManifest string
+	environments    string
+	signKey         string
+	gpgProgram      string
+	verifyKeyring   string
+	encryptKey      []byte
+	metadata        map[string]string
+	importExtras    map[string]interface{}
+}
+
 func main() {
 	rootCmd = &cobra.Command{
 		Use:     "tus-uploader",
 		Short:   "TUS Uploader client to upload a file on a TUS server",
 		Long:    `TUS Uploader streams file to a target URL.`,
 		Example: `./tus-uploader --vra-username=admin --vra-password=XXX Infoblox.zip https://vrahost/provisioning/ipam/api/providers/packages/import`,
+		Args:    cobra.ArbitraryArgs,
 		RunE:    execute,
+		Version: version,
+		// errors are reported by main, in the format of the logs
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
-	rootCmd.Flags().String("source", "", "path to the file to upload")
-	rootCmd.Flags().String("target", "", "url to upload to")
-	rootCmd.Flags().StringSlice("header", nil, "Extra headers. eg: Authorization: Bearer")
+	rootCmd.SetVersionTemplate(versionInfo())
+	rootCmd.AddCommand(newVersionCmd())
+	rootCmd.AddCommand(newCompletionCmd())
+	rootCmd.AddCommand(newSelfUpdateCmd())
+	rootCmd.AddCommand(newMockServerCmd())
+	rootCmd.AddCommand(newDownloadCmd())
+	rootCmd.AddCommand(newStatusCmd())
+	rootCmd.AddCommand(newDoctorCmd())
+	rootCmd.AddCommand(newConfigCmd())
+	rootCmd.Flags().StringArray("source", nil, "path to the file to upload, can be repeated")
+	rootCmd.Flags().String("target", "", "url to upload to. srv://_tus._tcp.example.com/path (https) or srv+http://... to pick the host and port from the DNS SRV records")
+	rootCmd.Flags().StringArray("header", nil, "Extra header, can be repeated. A value starting with @ is read from a file. eg: 'X-Token: @/path/to/file'. A value containing {{ }} is a template evaluated for every request with .Method, .URL, .Path, .Counter, .Time, .Timestamp and the functions hmac, base64 and env. eg: 'X-Signature: {{hmac \"sha256\" (env \"SECRET\") .Path}}'")
+	rootCmd.Flags().StringArray("header-from-env", nil, "Extra header with its value read from an environment variable, can be repeated. eg: 'X-Api-Key=MY_SECRET_ENV'")
 	rootCmd.Flags().Bool("skip-ssl-verification", false, "Set to true to skip the validation of the TLS certificates")
+	rootCmd.Flags().String("limit-rate-schedule", "", "Upload rate in Bytes per second by time of the day, 0 for no limit. The first matching window wins. eg: '08:00-18:00=5M,18:00-08:00=0'")
+	rootCmd.Flags().Bool("skip-offset-check", false, "Do not check the Upload-Offset of the server with a HEAD request once the upload is complete")
+	rootCmd.Flags().String("hmac-key", "", "Shared secret signing every request with HMAC-SHA256 of the method, path, date and body hash. A value starting with @ is read from a file")
+	rootCmd.Flags().String("hmac-header", "X-Signature", "Header of the --hmac-key signature")
+	rootCmd.Flags().Bool("method-override", false, "Send the chunks with POST and X-HTTP-Method-Override: PATCH for the proxies that block PATCH")
 	rootCmd.Flags().String("vra-username", "", "VRA Username")
 	rootCmd.Flags().String("vra-password", "", "VRA Password")
+	addClientAuthFlags(rootCmd)
+	addTrustFlags(rootCmd)
 	rootCmd.Flags().Bool("vra-import", false, "VRA Import the bundle")
 	rootCmd.Flags().Bool("verbose", true, "When true outputs the vra-token")
-
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	rootCmd.Flags().String("user-agent", defaultUserAgent(), "User-Agent sent with every request")
+	rootCmd.Flags().String("checksum-algo", "sha256", "Checksum algorithm of the checksum features: sha1, sha256, sha512 or crc32")
+	rootCmd.Flags().Bool("checksum-sidecar", false, "After a successful upload write <file>.<algo> with the digest of the file, of the plaintext with --encrypt-key")
+	rootCmd.Flags().Bool("upload-manifest", false, "After a successful upload, upload <file>.manifest.json with the digests of the file and of its zip entries as a second upload to the target, of the plaintext with --encrypt-key")
+	rootCmd.Flags().Bool("upload-checksum", false, "Send the Upload-Checksum of every chunk (TUS checksum extension)")
+	rootCmd.Flags().String("environments", "", "YAML or JSON inventory of vRA environments, each with a target and the credentials of its login, the files are uploaded and imported in each")
+	rootCmd.Flags().String("release-manifest", "", "YAML or JSON file listing several bundles and their dependencies, uploaded and imported one after the other in the order of their dependencies")
+	rootCmd.Flags().String("metadata-file", "", "JSON or YAML file with the TUS metadata under 'metadata' and extra fields of the vRA import payload under 'import'")
+	rootCmd.Flags().String("sign-key", "", "GPG key used to write a detached signature <file>.asc that is sent as the signature TUS metadata")
+	rootCmd.Flags().String("verify-signature", "", "Keyring of trusted keys (exported with gpg --export). Refuse to upload a file whose detached signature <file>.asc or <file>.sig does not validate")
+	rootCmd.Flags().String("encrypt-key", "", "File with a 32 Bytes key (raw, hex or base64) used to encrypt the content with AES-256-GCM before the upload")
+	rootCmd.Flags().String("gpg-program", "gpg", "GPG executable")
+	rootCmd.Flags().Bool("dry-run", false, "Login, run the preflight checks and print what would be uploaded without transferring any data")
+	rootCmd.Flags().Bool("estimate", false, "Print the size, checksum, chunk count and ETA of the upload without performing it. The ETA is measured with a 1MB probe upload, only on servers with the termination extension to delete it")
+	rootCmd.Flags().String("journal", defaultJournalPath(), "File recording the successful uploads. Empty to disable")
+	rootCmd.Flags().String("resume-store", defaultResumeStorePath(), "File recording the url of the unfinished uploads so that a retry or a later run resumes them. Empty to disable")
+	rootCmd.Flags().Int64("resume-offset", -1, "Resume the unfinished upload of the --resume-store from this offset instead of the one of the HEAD response, for servers behind caches returning stale offsets")
+	rootCmd.Flags().Bool("yes", false, "Do not ask for the confirmation of --resume-offset")
+	rootCmd.Flags().String("if-changed", "", "Skip the upload when the file is unchanged since its last successful upload to the target: mtime (modification time and size) or checksum")
+	rootCmd.Flags().Lookup("if-changed").NoOptDefVal = "mtime"
+	rootCmd.Flags().String("start-at", "", "Wait until this time to start the uploads, eg in a maintenance window: 2024-06-01T02:00Z, '2024-06-01 02:00' (local time) or 02:00 (next occurrence)")
+	rootCmd.Flags().String("max-size", "", "Refuse to upload a file larger than this size, or than the Tus-Max-Size of the server. eg: 500MB or 2GiB")
+	rootCmd.Flags().Bool("follow", false, "Upload a file while it is still written, with a deferred length, until it does not grow for --follow-idle")
+	rootCmd.Flags().Duration("follow-idle", 30*time.Second, "With --follow, end the upload once the file did not grow for this duration")
+	rootCmd.Flags().String("on-change", "abort", "What to do when the file is modified during its upload: abort or restart the upload from the start")
+	rootCmd.Flags().Bool("no-lock", false, "Do not take the lock file preventing concurrent uploads of the same file to the same target")
+	rootCmd.Flags().Bool("continue-on-error", false, "When uploading several files, keep going after a failed upload and report a summary at the end")
+	rootCmd.Flags().Int("parallel", 1, "Number of files uploaded concurrently")
+	rootCmd.Flags().Int("max-per-host", 0, "Maximum number of concurrent uploads to the same host. 0 for no limit other than --parallel")
+	rootCmd.Flags().String("batch-report", "", "Print the per-file results at the end of the run on stdout: csv or json. The logs go to stderr")
+	rootCmd.Flags().String("output-template", "", "Print each result at the end of the run with a Go template of its fields: .File, .Target, .Size, .Duration, .Attempts, .UploadURL, .BundleID, .ManifestURL, .Status, .ImportStatus, .ProviderName, .ProviderVersion, .IntegrationsURL and .Error. The logs go to stderr. eg: '{{.UploadURL}} {{.ProviderVersion}}'")
+	rootCmd.Flags().String("log-format", "text", "Format of the output: text or json (one object per line)")
+	rootCmd.Flags().String("ci-format", "", "Emit the progress and the results as CI service messages: teamcity or jenkins")
+	rootCmd.Flags().Bool("container", false, "Tune the output for containers: json logs to stdout and no dashboard")
+	rootCmd.Flags().String("termination-log", "/dev/termination-log", "File where the result of the run is written as JSON when running in Kubernetes")
+	rootCmd.Flags().Bool("tui", false, "Show a live dashboard of the uploads instead of progress lines")
+	rootCmd.Flags().String("progress", "auto", "Progress output: bar, plain (lines), quiet or auto (a bar on a terminal, otherwise plain lines every 10% or 30s)")
+	rootCmd.Flags().Duration("progress-interval", 0, "Minimum time between two progress lines. eg: 30s")
+	rootCmd.Flags().Int64("progress-percent", 0, "Minimum progress in percent between two progress lines. eg: 5")
+
+	handleSignals()
+	cmd, err := rootCmd.ExecuteC()
+	if cmd == rootCmd {
+		if termErr := writeTerminationMessage(rootCmd, err); termErr != nil {
+			console.Printf("Warning: unable to write the termination message: %s\n", termErr.Error())
+		}
+	}
+	if err != nil {
+		console.Printf("%s\n", err)
+		if githubActions() && cmd == rootCmd && !annotatedFailure() {
+			// on stdout, where GitHub Actions reads the workflow commands
+			fmt.Fprintf(os.Stdout, "::error title=tus-uploader failed::%s\n", escapeWorkflowCommand(err.Error()))
+		}
+		os.Exit(exitCode())
 	}
 }
 
 func execute(cmd *cobra.Command, args []string) error {
-	file, err := cmd.Flags().GetString("source")
+	files, err := cmd.Flags().GetStringArray("source")
 	if err != nil {
 		return err
 	}
@@ -56,147 +196,527 @@ func execute(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	headers, err := cmd.Flags().GetStringSlice("header")
+	opts, err := parseOptions(cmd)
 	if err != nil {
 		return err
 	}
-	skipTLSVerification, err := cmd.Flags().GetBool("skip-ssl-verification")
-	if err != nil {
-		return err
+	// the logs go to stderr so that the report is the only output on stdout, ready to be parsed
+	logs := os.Stdout
+	if opts.batchReport != "" || opts.outputTemplate != nil {
+		logs = os.Stderr
 	}
-	httpHeaders := make(http.Header)
-	for _, header := range headers {
-		toks := strings.SplitN(header, ":", 1)
-		if len(toks) != 2 {
-			return fmt.Errorf("Invalid header value '%s'. It must have a header-name:value separated by a column", headers)
+	console = newReporter(opts, logs)
+	defer console.Close()
+
+	for _, arg := range args {
+		if url == "" && (isURL(arg) || isSRVTarget(arg)) {
+			url = arg
+		} else {
+			files = append(files, arg)
 		}
-		httpHeaders.Add(toks[0], strings.TrimSpace(toks[1]))
 	}
-	vraUser, err := cmd.Flags().GetString("vra-username")
-	if err != nil {
-		return err
+	var dependencies map[string][]string
+	if opts.releaseManifest != "" {
+		bundles, err := loadReleaseManifest(opts.releaseManifest)
+		if err != nil {
+			return err
+		}
+		dependencies = make(map[string][]string)
+		bundleFiles := make(map[string]string)
+		for _, bundle := range bundles {
+			bundleFiles[bundle.Name] = bundle.File
+			for _, dependency := range bundle.DependsOn {
+				dependencies[bundle.File] = append(dependencies[bundle.File], bundleFiles[dependency])
+			}
+			files = append(files, bundle.File)
+		}
 	}
-	vraPassword, err := cmd.Flags().GetString("vra-password")
-	if err != nil {
-		return err
+	if len(files) == 0 {
+		return fmt.Errorf("Missing the file to upload")
 	}
-	vraImport, err := cmd.Flags().GetBool("vra-import")
-	if err != nil {
-		return err
+	envs := []environment{{Target: url}}
+	if opts.environments != "" {
+		if url != "" {
+			return fmt.Errorf("--environments and a target url cannot be combined")
+		}
+		if envs, err = loadEnvironments(opts.environments); err != nil {
+			return err
+		}
+	} else if url == "" {
+		return fmt.Errorf("Missing the url to upload to")
+	}
+	if opts.resumeOffset >= 0 && (len(files) > 1 || len(envs) > 1) {
+		return fmt.Errorf("--resume-offset resumes a single file")
+	}
+	if opts.follow && (len(files) > 1 || len(envs) > 1) {
+		return fmt.Errorf("--follow uploads a single file")
+	}
+	for i, env := range envs {
+		if isSRVTarget(env.Target) {
+			resolved, err := resolveSRVTarget(env.Target)
+			if err != nil {
+				return err
+			}
+			console.Printf("Resolved %s to %s\n", env.Target, resolved)
+			envs[i].Target = resolved
+		}
 	}
 
-	if vraUser != "" {
-		vraImport = true
+	// wait before the login so that the token does not expire in the meantime
+	if !opts.startAt.IsZero() && !opts.dryRun && !opts.estimate {
+		if err := waitUntil(opts.startAt); err != nil {
+			return err
+		}
 	}
 
-	for _, arg := range args {
-		if file == "" {
-			file = arg
-		} else if url == "" {
-			url = arg
+	// create a tus client per environment
+	httpClient := newHTTPClient(opts)
+	// the failed login of an environment is reported as the failure of its uploads
+	clients := make([]*tus.Client, len(envs))
+	loginErrs := make([]error, len(envs))
+	for i, env := range envs {
+		if clients[i], err = newTargetClient(env, opts, httpClient); err != nil {
+			return err
+		}
+		if env.Username != "" {
+			opts.vraImport = true
+		}
+		envOpts, err := environmentOptions(env, opts)
+		if err == nil {
+			err = loginTarget(env, envOpts, clients[i])
+		}
+		if err != nil {
+			if opts.environments == "" {
+				return err
+			}
+			loginErrs[i] = fmt.Errorf("Unable to log in the environment %s: %s", env.Name, err.Error())
+			console.Printf("%s\n", loginErrs[i])
 		}
 	}
-	f, err := os.Open(file)
-	if err != nil {
-		return err
+
+	if opts.dryRun || opts.estimate {
+		var firstErr error
+		for i, client := range clients {
+			if loginErrs[i] != nil {
+				if firstErr == nil {
+					firstErr = loginErrs[i]
+				}
+				continue
+			}
+			for _, file := range files {
+				if err := preview(file, client, opts); err != nil {
+					return err
+				}
+			}
+		}
+		return firstErr
 	}
 
-	defer f.Close()
+	if opts.resumeStore != nil {
+		for _, client := range clients {
+			client.Config.Resume = true
+			client.Config.Store = opts.resumeStore
+		}
+	}
 
-	fmt.Printf("TUS Uploading %s to %s\n", file, url)
+	// the progress of the batch is per file: it is not reported when a file is uploaded to several environments
+	if len(files) > 1 && len(envs) == 1 {
+		sizes := make(map[string]int64)
+		for _, file := range files {
+			if fi, err := os.Stat(file); err == nil {
+				sizes[file] = fi.Size()
+			} else {
+				sizes[file] = 0
+			}
+		}
+		totals = newBatchProgress(sizes)
+	}
 
-	// create the tus client.
-	clientConfig := tus.DefaultConfig()
-	clientConfig.Header = httpHeaders
-	if skipTLSVerification {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	var jobs []uploadJob
+	for i, env := range envs {
+		for _, file := range files {
+			jobs = append(jobs, uploadJob{file: file, client: clients[i], environment: env.Name, loginErr: loginErrs[i], dependsOn: dependencies[file]})
 		}
-		clientConfig.HttpClient = &http.Client{Transport: tr}
 	}
-	client, err := tus.NewClient(url, clientConfig)
-	if err != nil {
-		return err
+	results := runBatch(jobs, opts)
+	var failed []string
+	var firstErr error
+	var started []*uploadResult
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		started = append(started, result)
+		if result.Error != "" {
+			failed = append(failed, result.File)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s", result.Error)
+			}
+		}
+	}
+	runResults = started
+	if opts.environments != "" {
+		printEnvironmentsSummary(envs, started)
+	}
+	if githubActions() {
+		if err := writeGitHubOutputs(started); err != nil {
+			console.Printf("Warning: unable to write the step outputs: %s\n", err.Error())
+		}
+	}
+	if opts.batchReport != "" {
+		console.Close()
+		if err := writeBatchReport(os.Stdout, opts.batchReport, started); err != nil {
+			return err
+		}
+	}
+	if opts.outputTemplate != nil {
+		console.Close()
+		if err := writeTemplateReport(os.Stdout, opts.outputTemplate, started); err != nil {
+			return err
+		}
+	}
+	if !opts.continueOnError {
+		return firstErr
+	}
+	if len(jobs) > 1 && opts.environments == "" {
+		console.Printf("Uploaded %d of %d files\n", len(jobs)-len(failed), len(jobs))
+		for _, file := range failed {
+			console.Printf("  failed: %s\n", file)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d uploads failed", len(failed), len(jobs))
+	}
+	return nil
+}
+
+// newReporter picks the reporter of the command line flags, before the first message of the run.
+// The reporter prints to logs.
+func newReporter(opts *options, logs io.Writer) reporter {
+	if opts.tui && !opts.dryRun && !opts.estimate {
+		return newTUIReporter(logs)
+	} else if opts.logFormat == "json" {
+		return newJSONReporter(logs, opts.progressInterval, opts.progressPercent)
+	} else if opts.ciFormat == "teamcity" {
+		return &teamcityReporter{plainReporter: newPlainReporter(logs, opts.progressInterval, opts.progressPercent)}
+	} else if opts.ciFormat == "jenkins" {
+		return &jenkinsReporter{plainReporter: newPlainReporter(logs, opts.progressInterval, opts.progressPercent)}
+	} else if githubActions() {
+		return &githubReporter{plainReporter: newPlainReporter(logs, opts.progressInterval, opts.progressPercent)}
+	} else if opts.progress == "bar" {
+		return &barReporter{out: logs}
+	} else if opts.progress == "quiet" {
+		return &quietReporter{plainReporter: newPlainReporter(logs, 0, 0)}
 	}
+	return newPlainReporter(logs, opts.progressInterval, opts.progressPercent)
+}
 
+// newTargetClient creates the tus client of an environment.
+// Each client has its own headers as the environments have their own tokens.
+func newTargetClient(env environment, opts *options, httpClient *http.Client) (*tus.Client, error) {
+	clientConfig := tus.DefaultConfig()
+	clientConfig.Header = opts.httpHeaders.Clone()
+	clientConfig.HttpClient = httpClient
+	clientConfig.OverridePatchMethod = opts.methodOverride
+	return tus.NewClient(env.Target, clientConfig)
+}
+
+// loginTarget logs the client of an environment in its vRA when requested.
+func loginTarget(env environment, opts *options, client *tus.Client) error {
 	if bearerToken != "" {
-		httpHeaders.Set("Authorization", "Bearer "+bearerToken)
-	} else if vraUser != "" {
-		vraToken, err := vraToken(vraUser, vraPassword, client, clientConfig)
+		client.Config.Header.Set("Authorization", "Bearer "+bearerToken)
+	} else if opts.vraUser != "" || opts.clientID != "" {
+		if env.Name != "" {
+			console.Printf("Logging in the environment %s\n", env.Name)
+		}
+		vraToken, err := vraLogin(opts, client, client.Config)
 		if err != nil {
 			return err
 		}
-		bearerToken = vraToken
-		httpHeaders.Set("Authorization", "Bearer "+bearerToken)
+		client.Config.Header.Set("Authorization", "Bearer "+vraToken)
 	}
+	return nil
+}
 
-	// (Optional) Create a chan to notify upload status
-	uploadChan := make(chan tus.Upload, 1)
-	go func() {
-		for uploadStatus := range uploadChan {
-			// Print the upload status
-			fmt.Printf("%s Completed %v%% %v Bytes of %v Bytes\n",
-				time.Now().Format("2006-01-02 15:04:05"),
-				uploadStatus.Progress(),
-				uploadStatus.Offset(),
-				uploadStatus.Size())
-		}
-	}()
+// clientToken is the bearer token the client logged in with, empty when it did not.
+func clientToken(client *tus.Client) string {
+	return strings.TrimPrefix(client.Config.Header.Get("Authorization"), "Bearer ")
+}
 
-	// create an upload from a file.
-	upload, err := tus.NewUploadFromFile(f)
+func parseOptions(cmd *cobra.Command) (*options, error) {
+	opts := &options{}
+	var err error
+	headers, err := cmd.Flags().GetStringArray("header")
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	var uploader *tus.Uploader
-
-	// Declare number of attempts
-	const attemps = 50
-	for i := 1; i <= attemps; i++ {
-		if i > 1 {
-			fmt.Printf("%s Attemp %v of %v\n", time.Now().Format("2006-01-02 15:04:05"), i, attemps)
+	if opts.httpHeaders, err = parseHeaders(headers); err != nil {
+		return nil, err
+	}
+	if opts.headerTemplates, err = splitHeaderTemplates(opts.httpHeaders); err != nil {
+		return nil, err
+	}
+	headersFromEnv, err := cmd.Flags().GetStringArray("header-from-env")
+	if err != nil {
+		return nil, err
+	}
+	if opts.secretHeaders, err = addHeadersFromEnv(opts.httpHeaders, headersFromEnv); err != nil {
+		return nil, err
+	}
+	if opts.skipTLSVerification, err = cmd.Flags().GetBool("skip-ssl-verification"); err != nil {
+		return nil, err
+	}
+	if opts.methodOverride, err = cmd.Flags().GetBool("method-override"); err != nil {
+		return nil, err
+	}
+	if opts.skipOffsetCheck, err = cmd.Flags().GetBool("skip-offset-check"); err != nil {
+		return nil, err
+	}
+	hmacKey, err := cmd.Flags().GetString("hmac-key")
+	if err != nil {
+		return nil, err
+	}
+	if opts.hmacKey, err = loadHMACKey(hmacKey); err != nil {
+		return nil, err
+	}
+	if opts.hmacHeader, err = cmd.Flags().GetString("hmac-header"); err != nil {
+		return nil, err
+	}
+	rateSchedule, err := cmd.Flags().GetString("limit-rate-schedule")
+	if err != nil {
+		return nil, err
+	}
+	if opts.rateSchedule, err = parseRateSchedule(rateSchedule); err != nil {
+		return nil, err
+	}
+	if opts.onChange, err = cmd.Flags().GetString("on-change"); err != nil {
+		return nil, err
+	}
+	if opts.onChange != "abort" && opts.onChange != "restart" {
+		return nil, fmt.Errorf("Invalid --on-change '%s': it must be abort or restart", opts.onChange)
+	}
+	startAt, err := cmd.Flags().GetString("start-at")
+	if err != nil {
+		return nil, err
+	}
+	if startAt != "" {
+		if opts.startAt, err = parseStartAt(startAt, time.Now()); err != nil {
+			return nil, err
 		}
-		// Create an uploader
-		uploader, err = client.CreateOrResumeUpload(upload)
-		if err != nil {
-			if i == 1 { // on the first error, see if the problem is recoverable or not
-				errMsg := err.Error()
-				if strings.Contains(errMsg, "403") || strings.Contains(errMsg, "401") || strings.Contains(errMsg, "404") || strings.Contains(errMsg, "400") {
-					break // Unrecoverable error
-				}
-			}
-			fmt.Println("Error", err)
-			fmt.Println("Trying again in 10 seconds")
-			time.Sleep(time.Second * 10)
-			continue
+	}
+	maxSize, err := cmd.Flags().GetString("max-size")
+	if err != nil {
+		return nil, err
+	}
+	if maxSize != "" {
+		if opts.maxSize, err = parseSize(maxSize); err != nil {
+			return nil, err
 		}
-		if i == 1 {
-			fmt.Printf("%s Starting the upload to %s\n", time.Now().Format("2006-01-02 15:04:05"), uploader.Url())
+	}
+	if opts.follow, err = cmd.Flags().GetBool("follow"); err != nil {
+		return nil, err
+	}
+	if opts.followIdle, err = cmd.Flags().GetDuration("follow-idle"); err != nil {
+		return nil, err
+	}
+	if opts.vraUser, err = cmd.Flags().GetString("vra-username"); err != nil {
+		return nil, err
+	}
+	if opts.vraPassword, err = cmd.Flags().GetString("vra-password"); err != nil {
+		return nil, err
+	}
+	if opts.vraImport, err = cmd.Flags().GetBool("vra-import"); err != nil {
+		return nil, err
+	}
+	if err := parseClientAuth(cmd, opts); err != nil {
+		return nil, err
+	}
+	if err := parseTrust(cmd, opts); err != nil {
+		return nil, err
+	}
+	if opts.vraUser != "" || opts.clientID != "" {
+		opts.vraImport = true
+	}
+	if opts.progressInterval, err = cmd.Flags().GetDuration("progress-interval"); err != nil {
+		return nil, err
+	}
+	if opts.progressPercent, err = cmd.Flags().GetInt64("progress-percent"); err != nil {
+		return nil, err
+	}
+	if opts.progress, err = cmd.Flags().GetString("progress"); err != nil {
+		return nil, err
+	}
+	switch opts.progress {
+	case "auto":
+		if isTerminal() {
+			opts.progress = "bar"
+		} else {
+			opts.progress = "plain"
+			// Neither flood the CI logs nor stay silent for minutes
+			if !cmd.Flags().Changed("progress-interval") && !cmd.Flags().Changed("progress-percent") {
+				opts.progressInterval = 30 * time.Second
+				opts.progressPercent = 10
+			}
 		}
-		// (Optional) Notify Upload Status
-		uploader.NotifyUploadProgress(uploadChan)
-		// Start upload to server
-		err = uploader.Upload()
+	case "bar", "plain", "quiet":
+	default:
+		return nil, fmt.Errorf("Invalid --progress '%s'. It must be auto, bar, plain or quiet", opts.progress)
+	}
+	if opts.userAgent, err = cmd.Flags().GetString("user-agent"); err != nil {
+		return nil, err
+	}
+	if opts.checksumAlgo, err = cmd.Flags().GetString("checksum-algo"); err != nil {
+		return nil, err
+	}
+	if _, err := newHash(opts.checksumAlgo); err != nil {
+		return nil, err
+	}
+	if opts.checksumSidecar, err = cmd.Flags().GetBool("checksum-sidecar"); err != nil {
+		return nil, err
+	}
+	if opts.uploadManifest, err = cmd.Flags().GetBool("upload-manifest"); err != nil {
+		return nil, err
+	}
+	if opts.uploadChecksum, err = cmd.Flags().GetBool("upload-checksum"); err != nil {
+		return nil, err
+	}
+	metadataFile, err := cmd.Flags().GetString("metadata-file")
+	if err != nil {
+		return nil, err
+	}
+	if metadataFile != "" {
+		loaded, err := loadMetadataFile(metadataFile)
 		if err != nil {
-			fmt.Println("Error", err)
-			fmt.Println("Trying again in 10 seconds")
-			time.Sleep(time.Second * 10)
-			continue
+			return nil, err
 		}
-		break
+		opts.metadata = loaded.Metadata
+		opts.importExtras = loaded.Import
 	}
-
+	if opts.signKey, err = cmd.Flags().GetString("sign-key"); err != nil {
+		return nil, err
+	}
+	if opts.gpgProgram, err = cmd.Flags().GetString("gpg-program"); err != nil {
+		return nil, err
+	}
+	if opts.verifyKeyring, err = cmd.Flags().GetString("verify-signature"); err != nil {
+		return nil, err
+	}
+	encryptKey, err := cmd.Flags().GetString("encrypt-key")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	fmt.Printf("%s Done uploading\n", time.Now().Format("2006-01-02 15:04:05"))
-
-	if vraImport && bearerToken != "" {
-		err = vraImportBundle(bearerToken, client, uploader, clientConfig)
+	if encryptKey != "" {
+		if opts.encryptKey, err = loadEncryptionKey(encryptKey); err != nil {
+			return nil, err
+		}
+	}
+	if opts.dryRun, err = cmd.Flags().GetBool("dry-run"); err != nil {
+		return nil, err
+	}
+	if opts.estimate, err = cmd.Flags().GetBool("estimate"); err != nil {
+		return nil, err
+	}
+	if opts.journalPath, err = cmd.Flags().GetString("journal"); err != nil {
+		return nil, err
+	}
+	opts.journal = openJournal(opts.journalPath)
+	resumeStorePath, err := cmd.Flags().GetString("resume-store")
+	if err != nil {
+		return nil, err
 	}
+	opts.resumeStore = openResumeStore(resumeStorePath)
+	if opts.resumeOffset, err = cmd.Flags().GetInt64("resume-offset"); err != nil {
+		return nil, err
+	}
+	if opts.yes, err = cmd.Flags().GetBool("yes"); err != nil {
+		return nil, err
+	}
+	if opts.resumeOffset >= 0 && opts.resumeStore == nil {
+		return nil, fmt.Errorf("--resume-offset requires a --resume-store")
+	}
+	if opts.resumeOffset >= 0 && opts.encryptKey != nil {
+		return nil, fmt.Errorf("--resume-offset can not resume an encrypted upload")
+	}
+	if opts.follow && (opts.encryptKey != nil || opts.signKey != "" || opts.resumeOffset >= 0) {
+		return nil, fmt.Errorf("--follow can not be combined with --encrypt-key, --sign-key or --resume-offset")
+	}
+	if opts.ifChanged, err = cmd.Flags().GetString("if-changed"); err != nil {
+		return nil, err
+	}
+	if opts.ifChanged != "" && opts.ifChanged != "mtime" && opts.ifChanged != "checksum" {
+		return nil, fmt.Errorf("Invalid --if-changed '%s'. It must be mtime or checksum", opts.ifChanged)
+	}
+	if opts.ifChanged != "" && opts.journal == nil {
+		return nil, fmt.Errorf("--if-changed requires a --journal")
+	}
+	if opts.noLock, err = cmd.Flags().GetBool("no-lock"); err != nil {
+		return nil, err
+	}
+	if opts.continueOnError, err = cmd.Flags().GetBool("continue-on-error"); err != nil {
+		return nil, err
+	}
+	if opts.parallel, err = cmd.Flags().GetInt("parallel"); err != nil {
+		return nil, err
+	}
+	if opts.maxPerHost, err = cmd.Flags().GetInt("max-per-host"); err != nil {
+		return nil, err
+	}
+	if opts.releaseManifest, err = cmd.Flags().GetString("release-manifest"); err != nil {
+		return nil, err
+	}
+	if opts.environments, err = cmd.Flags().GetString("environments"); err != nil {
+		return nil, err
+	}
+	if opts.releaseManifest != "" && opts.parallel > 1 {
+		return nil, fmt.Errorf("--release-manifest imports the bundles one after the other and cannot be combined with --parallel")
+	}
+	if opts.batchReport, err = cmd.Flags().GetString("batch-report"); err != nil {
+		return nil, err
+	}
+	if opts.batchReport != "" && opts.batchReport != "csv" && opts.batchReport != "json" {
+		return nil, fmt.Errorf("Invalid --batch-report '%s'. It must be csv or json", opts.batchReport)
+	}
+	outputTemplate, err := cmd.Flags().GetString("output-template")
+	if err != nil {
+		return nil, err
+	}
+	if outputTemplate != "" {
+		if opts.batchReport != "" {
+			return nil, fmt.Errorf("--output-template and --batch-report cannot be combined")
+		}
+		if opts.outputTemplate, err = parseOutputTemplate(outputTemplate); err != nil {
+			return nil, err
+		}
+	}
+	if opts.tui, err = cmd.Flags().GetBool("tui"); err != nil {
+		return nil, err
+	}
+	if opts.logFormat, err = cmd.Flags().GetString("log-format"); err != nil {
+		return nil, err
+	}
+	if opts.logFormat != "text" && opts.logFormat != "json" {
+		return nil, fmt.Errorf("Invalid --log-format '%s'. It must be text or json", opts.logFormat)
+	}
+	if opts.ciFormat, err = cmd.Flags().GetString("ci-format"); err != nil {
+		return nil, err
+	}
+	if opts.ciFormat != "" && opts.ciFormat != "teamcity" && opts.ciFormat != "jenkins" {
+		return nil, fmt.Errorf("Invalid --ci-format '%s'. It must be teamcity or jenkins", opts.ciFormat)
+	}
+	container, err := cmd.Flags().GetBool("container")
+	if err != nil {
+		return nil, err
+	}
+	if container {
+		opts.logFormat = "json"
+		opts.tui = false
+	}
+	return opts, nil
+}
 
-	return nil
+func isURL(arg string) bool {
+	u, err := netURL.Parse(arg)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
 }
 
 func vraToken(username, password string, client *tus.Client, clientConfig *tus.Config) (string, error) {
@@ -240,18 +760,31 @@ func vraToken(username, password string, client *tus.Client, clientConfig *tus.C
 	return respAsMap["access_token"].(string), nil
 }
 
-func vraImportBundle(bearerToken string, client *tus.Client, uploader *tus.Uploader, clientConfig *tus.Config) error {
-	toks := strings.Split(uploader.Url(), "/")
-	bundleID := toks[len(toks)-1]
+func vraImportPayload(bundleID string, extras map[string]interface{}) map[string]interface{} {
+	payload := map[string]interface{}{
+		"option": "OVERWRITE",
+	}
+	for k, v := range extras {
+		payload[k] = v
+	}
+	payload["bundleId"] = bundleID
+	return payload
+}
 
-	payload, err := json.Marshal(map[string]string{
-		"bundleId": bundleID,
-		"option":   "OVERWRITE",
-	})
+// bundleIDFromURL returns the id of the bundle that vRA assigned to an upload: the last segment of its url.
+func bundleIDFromURL(uploadURL string) string {
+	toks := strings.Split(uploadURL, "/")
+	return toks[len(toks)-1]
+}
+
+func vraImportBundle(bearerToken string, client *tus.Client, uploader *tus.Uploader, clientConfig *tus.Config, extras map[string]interface{}) (string, string, string, error) {
+	bundleID := bundleIDFromURL(uploader.Url())
+
+	payload, err := json.Marshal(vraImportPayload(bundleID, extras))
 	if err != nil {
-		return err
+		return "", "", "", err
 	}
-	fmt.Printf("Importing the bundle in VRA %s/%s\n", client.Url, bundleID)
+	console.Printf("Importing the bundle in VRA %s/%s\n", client.Url, bundleID)
 
 	request, err := http.NewRequest("POST", client.Url, bytes.NewBuffer(payload))
 	request.Header.Set("Authorization", "Bearer "+bearerToken)
@@ -259,29 +792,38 @@ func vraImportBundle(bearerToken string, client *tus.Client, uploader *tus.Uploa
 
 	response, err := clientConfig.HttpClient.Do(request)
 	if err != nil {
-		return err
+		return "", "", "", err
 	}
 
 	defer response.Body.Close()
 
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return err
+		return "", "", "", err
+	}
+	if response.StatusCode != 201 {
+		return "", "", "", vraImportError(bearerToken, client.Url, clientConfig, response, body)
 	}
 	respAsMap := make(map[string]interface{})
 	err = json.Unmarshal(body, &respAsMap)
 	if err != nil {
-		return err
-	}
-
-	if response.StatusCode == 201 {
-		fmt.Printf("Bundle imported into VRA: %s %s\n", respAsMap["providerName"].(string), respAsMap["providerVersion"].(string))
-		return nil
+		return "", "", "", err
 	}
 
-	fmt.Println("response Status:", response.Status)
-	fmt.Println("response Headers:", response.Header)
-	fmt.Println("response Body:", string(body))
+	providerName, _ := respAsMap["providerName"].(string)
+	providerVersion, _ := respAsMap["providerVersion"].(string)
+	integrationsURL := vraIntegrationsUIURL(client.Url)
+	console.Printf("Bundle imported into VRA: %s %s\n", providerName, providerVersion)
+	console.Printf("List the integrations of the VRA UI to check the provider: %s\n", integrationsURL)
+	return providerName, providerVersion, integrationsURL, nil
+}
 
-	return fmt.Errorf("Failed to import the bundle. StatusCode was '%s' instead of 200/OK", response.Status)
+// vraIntegrationsUIURL is the page of the vRA UI listing the integrations, where an imported provider can be
+// selected. The import response only reports the name and version of the provider, not a page of its own.
+func vraIntegrationsUIURL(importURL string) string {
+	baseURL, err := netURL.Parse(importURL)
+	if err != nil {
+		return ""
+	}
+	return baseURL.Scheme + "://" + baseURL.Host + "/automation-ui/#/provisioning-ui;ash=%2Fintegrations"
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/eventials/go-tus"
+)
+
+// checksumManifest lists the digests of a bundle and of its entries so that the receiving side can
+// validate the integrity of an upload independently of the TUS server.
+type checksumManifest struct {
+	Bundle    string          `json:"bundle"`
+	UploadURL string          `json:"uploadUrl"`
+	Size      int64           `json:"size"`
+	Checksum  string          `json:"checksum"`
+	Files     []manifestEntry `json:"files,omitempty"`
+}
+
+type manifestEntry struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+// newChecksumManifest computes the manifest of a file, listing its entries when it is a zip archive.
+func newChecksumManifest(file, uploadURL, algo string, stream *streamDigest) (*checksumManifest, error) {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := stream.fileDigest(file, algo, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	manifest := &checksumManifest{
+		Bundle:    filepath.Base(file),
+		UploadURL: uploadURL,
+		Size:      fi.Size(),
+		Checksum:  algo + ":" + digest,
+	}
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		// Not an archive: the manifest only has the digest of the file
+		return manifest, nil
+	}
+	defer r.Close()
+	for _, entry := range r.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		digest, err := zipEntryDigest(entry, algo)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, manifestEntry{
+			Name:     entry.Name,
+			Size:     int64(entry.UncompressedSize64),
+			Checksum: algo + ":" + digest,
+		})
+	}
+	return manifest, nil
+}
+
+func zipEntryDigest(entry *zip.File, algo string) (string, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return "", err
+	}
+	rc, err := entry.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// uploadChecksumManifest uploads the manifest of a bundle as a second upload to the same target.
+// Its metadata links it to the bundle: type=checksum-manifest and bundleId.
+func uploadChecksumManifest(file, uploadURL string, digest *streamDigest, client *tus.Client, opts *options) (string, error) {
+	manifest, err := newChecksumManifest(file, uploadURL, opts.checksumAlgo, digest)
+	if err != nil {
+		return "", err
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	upload := tus.NewUploadFromBytes(content)
+	upload.Metadata["filename"] = manifest.Bundle + ".manifest.json"
+	upload.Metadata["filetype"] = "application/json"
+	upload.Metadata["type"] = "checksum-manifest"
+	upload.Metadata["bundleId"] = bundleIDFromURL(uploadURL)
+	// The resume store requires a fingerprint
+	upload.Fingerprint = "manifest " + uploadURL
+	if opts.resumeStore != nil {
+		defer opts.resumeStore.Delete(upload.Fingerprint)
+	}
+	uploader, err := client.CreateUpload(upload)
+	if err != nil {
+		return "", err
+	}
+	if err := uploader.Upload(); err != nil {
+		return "", err
+	}
+	return uploader.Url(), nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eventials/go-tus"
+)
+
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+	{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize parses a number of Bytes with an optional unit. eg: 500MB, 2GiB or 1G (same as 1GiB)
+func parseSize(value string) (int64, error) {
+	text := strings.TrimSpace(value)
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(strings.ToUpper(text), strings.ToUpper(unit.suffix)) {
+			text = strings.TrimSpace(text[:len(text)-len(unit.suffix)])
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("Invalid size '%s'. eg: 500MB, 2GiB or 1073741824", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// checkMaxSize refuses a file larger than --max-size or than the Tus-Max-Size of the server.
+func checkMaxSize(file string, size int64, client *tus.Client, maxSize int64) error {
+	if size > maxSize {
+		return fmt.Errorf("%s is %d Bytes which is larger than the --max-size of %d Bytes", file, size, maxSize)
+	}
+	caps, err := tusPreflight(client)
+	if err != nil {
+		return err
+	}
+	if caps.MaxSize > 0 && size > caps.MaxSize {
+		return fmt.Errorf("%s is %d Bytes which is larger than the Tus-Max-Size of %d Bytes of %s", file, size, caps.MaxSize, client.Url)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// uploadMetadata is loaded by --metadata-file. eg:
+//
+//	metadata:
+//	  version: 1.2.0
+//	  buildUrl: https://ci/job/42
+//	import:
+//	  option: OVERWRITE
+type uploadMetadata struct {
+	// Metadata is sent as the TUS Upload-Metadata
+	Metadata map[string]string
+	// Import is merged into the payload of the vRA import
+	Import map[string]interface{}
+}
+
+func loadMetadataFile(path string) (*uploadMetadata, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := struct {
+		Metadata map[string]interface{} `json:"metadata" yaml:"metadata"`
+		Import   map[string]interface{} `json:"import" yaml:"import"`
+	}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	default:
+		err = json.Unmarshal(content, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid metadata file %s: %s", path, err.Error())
+	}
+
+	loaded := &uploadMetadata{Metadata: make(map[string]string)}
+	for k, v := range raw.Metadata {
+		if strings.ContainsAny(k, " ,") {
+			return nil, fmt.Errorf("Invalid metadata file %s: the key '%s' must not contain spaces or commas", path, k)
+		}
+		switch v := v.(type) {
+		case string:
+			loaded.Metadata[k] = v
+		case nil:
+			loaded.Metadata[k] = ""
+		case map[interface{}]interface{}, map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("Invalid metadata file %s: the value of '%s' must be a scalar", path, k)
+		default:
+			loaded.Metadata[k] = fmt.Sprint(v)
+		}
+	}
+	if raw.Import != nil {
+		loaded.Import = jsonCompatible(raw.Import).(map[string]interface{})
+		if _, ok := loaded.Import["bundleId"]; ok {
+			return nil, fmt.Errorf("Invalid metadata file %s: the bundleId of the import is assigned by the server", path)
+		}
+	}
+	return loaded, nil
+}
+
+// jsonCompatible converts the map[interface{}]interface{} decoded from YAML into map[string]interface{}.
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			m[fmt.Sprint(k)] = jsonCompatible(value)
+		}
+		return m
+	case map[string]interface{}:
+		for k, value := range v {
+			v[k] = jsonCompatible(value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = jsonCompatible(value)
+		}
+		return v
+	}
+	return v
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eventials/go-tus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	mockUploadsPath = "/files/"
+	mockImportPath  = "/provisioning/ipam/api/providers/packages/import"
+	mockLoginPath   = "/csp/gateway/am/api/login"
+	mockTokenPath   = cspTokenPath
+)
+
+type mockUpload struct {
+	length   int64
+	metadata string
+	data     []byte
+	expires  time.Time
+}
+
+// mockServer is a tusd compatible endpoint storing the uploads in memory,
+// with a fake CSP login and vRA import endpoint.
+type mockServer struct {
+	latency     time.Duration
+	failureRate float64
+	username    string
+	password    string
+	maxSize     int64
+	expiration  time.Duration
+
+	mu      sync.Mutex
+	uploads map[string]*mockUpload
+	counter int
+	// failedImports are the messages of the request trackers of the failed imports
+	failedImports map[string]string
+}
+
+func newMockServerCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mock-server",
+		Short: "Run a local TUS server with a fake vRA login and import endpoint for testing",
+		Long: `Runs a local tusd compatible server that keeps the uploads in memory.
+
+Uploads go to ` + mockUploadsPath + ` and vRA imports to ` + mockImportPath + `. eg:
+  tus-uploader mock-server --listen localhost:1080 &
+  tus-uploader --vra-username=admin --vra-password=admin Infoblox.zip http://localhost:1080` + mockImportPath,
+		Args: cobra.NoArgs,
+		RunE: runMockServer,
+	}
+	cmd.Flags().String("listen", "localhost:1080", "Address to listen on")
+	cmd.Flags().Duration("latency", 0, "Delay added to every request")
+	cmd.Flags().Float64("failure-rate", 0, "Ratio of the chunks that fail with a 500 error, between 0 and 1")
+	cmd.Flags().String("username", "admin", "Username accepted by the fake CSP login")
+	cmd.Flags().String("password", "admin", "Password accepted by the fake CSP login")
+	cmd.Flags().Int64("max-size", 0, "Tus-Max-Size advertised by the server. 0 for no limit")
+	cmd.Flags().Duration("expiration", 0, "Delete the incomplete uploads after this duration (expiration extension). 0 to keep them")
+	return cmd
+}
+
+func runMockServer(cmd *cobra.Command, args []string) error {
+	listen, err := cmd.Flags().GetString("listen")
+	if err != nil {
+		return err
+	}
+	server := &mockServer{uploads: make(map[string]*mockUpload), failedImports: make(map[string]string)}
+	if server.latency, err = cmd.Flags().GetDuration("latency"); err != nil {
+		return err
+	}
+	if server.failureRate, err = cmd.Flags().GetFloat64("failure-rate"); err != nil {
+		return err
+	}
+	if server.username, err = cmd.Flags().GetString("username"); err != nil {
+		return err
+	}
+	if server.password, err = cmd.Flags().GetString("password"); err != nil {
+		return err
+	}
+	if server.maxSize, err = cmd.Flags().GetInt64("max-size"); err != nil {
+		return err
+	}
+	if server.expiration, err = cmd.Flags().GetDuration("expiration"); err != nil {
+		return err
+	}
+
+	fmt.Printf("Mock TUS server listening on http://%s\n", listen)
+	fmt.Printf("  Uploads: http://%s%s\n", listen, mockUploadsPath)
+	fmt.Printf("  Import:  http://%s%s\n", listen, mockImportPath)
+	return http.ListenAndServe(listen, server)
+}
+
+func (s *mockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("%s %s %s\n", time.Now().Format(timestampFormat), r.Method, r.URL.Path)
+	if s.latency > 0 {
+		time.Sleep(s.latency)
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, mockLoginPath) && r.Method == "POST":
+		s.login(w, r)
+	case r.URL.Path == mockTokenPath && r.Method == "POST":
+		s.token(w, r)
+	case r.URL.Path == mockImportPath && r.Method == "POST" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/json"):
+		s.importBundle(w, r)
+	case isMockCreationPath(r.URL.Path) && r.Method == "OPTIONS":
+		s.options(w)
+	case isMockCreationPath(r.URL.Path) && r.Method == "POST":
+		s.create(w, r)
+	case strings.HasPrefix(r.URL.Path, requestTrackerPath) && r.Method == "GET":
+		s.requestTracker(w, r, strings.TrimPrefix(r.URL.Path, requestTrackerPath))
+	case strings.HasPrefix(r.URL.Path, mockUploadsPath):
+		s.upload(w, r, strings.TrimPrefix(r.URL.Path, mockUploadsPath))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// isMockCreationPath accepts both the generic tusd path and the vRA import path.
+func isMockCreationPath(path string) bool {
+	return path == mockImportPath || strings.TrimSuffix(path, "/")+"/" == mockUploadsPath
+}
+
+func (s *mockServer) options(w http.ResponseWriter) {
+	w.Header().Set("Tus-Resumable", tus.ProtocolVersion)
+	w.Header().Set("Tus-Version", tus.ProtocolVersion)
+	extensions := "creation,creation-defer-length,termination,checksum"
+	if s.expiration > 0 {
+		extensions += ",expiration"
+	}
+	w.Header().Set("Tus-Extension", extensions)
+	w.Header().Set("Tus-Checksum-Algorithm", "sha1,sha256,sha512,crc32")
+	if s.maxSize > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(s.maxSize, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *mockServer) create(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tus.ProtocolVersion)
+	length := int64(-1)
+	if r.Header.Get("Upload-Defer-Length") != "1" {
+		var err error
+		length, err = strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		if err != nil || length < 0 {
+			http.Error(w, "Invalid Upload-Length", http.StatusBadRequest)
+			return
+		}
+		if s.maxSize > 0 && length > s.maxSize {
+			http.Error(w, "Upload-Length exceeds Tus-Max-Size", http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+	s.mu.Lock()
+	s.counter++
+	id := fmt.Sprintf("%d%06d", time.Now().Unix(), s.counter)
+	var expires time.Time
+	if s.expiration > 0 {
+		expires = time.Now().Add(s.expiration)
+	}
+	s.uploads[id] = &mockUpload{length: length, metadata: r.Header.Get("Upload-Metadata"), expires: expires}
+	s.mu.Unlock()
+	if !expires.IsZero() {
+		w.Header().Set("Upload-Expires", expires.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Location", mockUploadsPath+id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *mockServer) upload(w http.ResponseWriter, r *http.Request, id string) {
+	w.Header().Set("Tus-Resumable", tus.ProtocolVersion)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	upload, ok := s.uploads[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if complete := int64(len(upload.data)) == upload.length; !complete && !upload.expires.IsZero() {
+		if time.Now().After(upload.expires) {
+			delete(s.uploads, id)
+			http.Error(w, "Upload expired", http.StatusGone)
+			return
+		}
+		w.Header().Set("Upload-Expires", upload.expires.UTC().Format(http.TimeFormat))
+	}
+	method := r.Method
+	if override := r.Header.Get("X-HTTP-Method-Override"); override != "" {
+		method = override
+	}
+	switch method {
+	case "HEAD":
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(upload.data)))
+		if upload.length >= 0 {
+			w.Header().Set("Upload-Length", strconv.FormatInt(upload.length, 10))
+		} else {
+			w.Header().Set("Upload-Defer-Length", "1")
+		}
+		if upload.metadata != "" {
+			w.Header().Set("Upload-Metadata", upload.metadata)
+		}
+		w.WriteHeader(http.StatusOK)
+	case "GET":
+		w.Header().Set("Content-Length", strconv.Itoa(len(upload.data)))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(upload.data)
+	case "DELETE":
+		delete(s.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	case "PATCH":
+		if s.failureRate > 0 && rand.Float64() < s.failureRate {
+			http.Error(w, "Simulated failure", http.StatusInternalServerError)
+			return
+		}
+		offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil || offset != int64(len(upload.data)) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if upload.length < 0 && r.Header.Get("Upload-Length") != "" {
+			if upload.length, err = strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64); err != nil {
+				http.Error(w, "Invalid Upload-Length", http.StatusBadRequest)
+				return
+			}
+		}
+		chunk, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if checksum := r.Header.Get("Upload-Checksum"); checksum != "" && !validChecksum(checksum, chunk) {
+			http.Error(w, "Checksum mismatch", 460)
+			return
+		}
+		if upload.length >= 0 && offset+int64(len(chunk)) > upload.length {
+			http.Error(w, "Chunk exceeds Upload-Length", http.StatusRequestEntityTooLarge)
+			return
+		}
+		upload.data = append(upload.data, chunk...)
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(upload.data)))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func validChecksum(header string, chunk []byte) bool {
+	toks := strings.SplitN(header, " ", 2)
+	if len(toks) != 2 {
+		return false
+	}
+	h, err := newHash(toks[0])
+	if err != nil {
+		return false
+	}
+	h.Write(chunk)
+	return toks[1] == base64Encode(h.Sum(nil))
+}
+
+func (s *mockServer) login(w http.ResponseWriter, r *http.Request) {
+	credentials := map[string]string{}
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if credentials["username"] != s.username || credentials["password"] != s.password {
+		http.Error(w, `{"message":"Invalid username or password"}`, http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"access_token": fmt.Sprintf("mock-token-%d", time.Now().UnixNano())})
+}
+
+// token accepts the client credentials grant of any client with a well formed and unexpired JWT assertion.
+// The signature is not verified: the mock does not know the public keys of the clients.
+func (s *mockServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("client_assertion_type") != jwtBearerAssertion {
+		http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+		return
+	}
+	parts := strings.Split(r.PostForm.Get("client_assertion"), ".")
+	var claims struct {
+		Issuer  string `json:"iss"`
+		Expires int64  `json:"exp"`
+	}
+	if len(parts) == 3 {
+		if payload, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil {
+			json.Unmarshal(payload, &claims)
+		}
+	}
+	if claims.Issuer == "" || claims.Issuer != r.PostForm.Get("client_id") || claims.Expires < time.Now().Unix() {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": fmt.Sprintf("mock-token-%d", time.Now().UnixNano()),
+		"token_type":   "bearer",
+		"expires_in":   1800,
+	})
+}
+
+func (s *mockServer) requestTracker(w http.ResponseWriter, r *http.Request, id string) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer mock-token-") {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"message":"Unauthorized"}`)
+		return
+	}
+	s.mu.Lock()
+	message, ok := s.failedImports[id]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requestTracker{ID: id, Name: "Import provider package", Progress: 100, Status: "FAILED", Message: message})
+}
+
+func (s *mockServer) importBundle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer mock-token-") {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"message":"Unauthorized"}`)
+		return
+	}
+	payload := map[string]interface{}{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+		return
+	}
+	bundleID, _ := payload["bundleId"].(string)
+	s.mu.Lock()
+	upload, ok := s.uploads[bundleID]
+	s.mu.Unlock()
+	if !ok || int64(len(upload.data)) != upload.length {
+		message := fmt.Sprintf("Bundle '%s' not found or incomplete", bundleID)
+		s.mu.Lock()
+		s.counter++
+		trackerID := fmt.Sprintf("import-%d", s.counter)
+		s.failedImports[trackerID] = message
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":          message,
+			"statusCode":       http.StatusBadRequest,
+			"errorCode":        90001,
+			"serverErrorId":    fmt.Sprintf("mock-%d", time.Now().UnixNano()),
+			"requestTrackerId": trackerID,
+		})
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":              bundleID,
+		"providerName":    "mock-provider",
+		"providerVersion": "1.0.0",
+	})
+}
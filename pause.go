@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sync"
+)
+
+// pauseGate holds the chunks while the uploads are paused with SIGUSR1, until SIGUSR2.
+type pauseGate struct {
+	mu      sync.Mutex
+	resumed chan struct{}
+}
+
+var pause = &pauseGate{}
+
+// Pause returns false when the uploads are already paused.
+func (g *pauseGate) Pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		return false
+	}
+	g.resumed = make(chan struct{})
+	return true
+}
+
+// Resume returns false when the uploads are not paused.
+func (g *pauseGate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		return false
+	}
+	close(g.resumed)
+	g.resumed = nil
+	return true
+}
+
+// wait blocks while the uploads are paused, unless the run is interrupted.
+func (g *pauseGate) wait() error {
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-interrupted:
+		return errInterrupted
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eventials/go-tus"
+)
+
+// serverCapabilities is what a TUS server advertises in its OPTIONS response.
+type serverCapabilities struct {
+	Versions   []string
+	Extensions []string
+	MaxSize    int64
+}
+
+func (s *serverCapabilities) supports(extension string) bool {
+	for _, ext := range s.Extensions {
+		if ext == extension {
+			return true
+		}
+	}
+	return false
+}
+
+// tusPreflight sends an OPTIONS request to the target to discover what the server supports.
+func tusPreflight(client *tus.Client) (*serverCapabilities, error) {
+	request, err := http.NewRequest("OPTIONS", client.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 && response.StatusCode != 204 {
+		return nil, fmt.Errorf("Preflight OPTIONS on %s failed. StatusCode was '%s' instead of 200/OK or 204/No Content", client.Url, response.Status)
+	}
+	caps := &serverCapabilities{
+		Versions:   splitHeaderList(response.Header.Get("Tus-Version")),
+		Extensions: splitHeaderList(response.Header.Get("Tus-Extension")),
+	}
+	if maxSize := response.Header.Get("Tus-Max-Size"); maxSize != "" {
+		caps.MaxSize, err = strconv.ParseInt(maxSize, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Tus-Max-Size '%s' returned by %s", maxSize, client.Url)
+		}
+	}
+	return caps, nil
+}
+
+func splitHeaderList(value string) []string {
+	var list []string
+	for _, tok := range strings.Split(value, ",") {
+		if tok = strings.TrimSpace(tok); tok != "" {
+			list = append(list, tok)
+		}
+	}
+	return list
+}
+
+// validateBundle checks that a vRA bundle is a readable zip archive and returns its number of entries.
+func validateBundle(path string) (int, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid bundle %s: %s", path, err.Error())
+	}
+	defer r.Close()
+	if len(r.File) == 0 {
+		return 0, fmt.Errorf("Invalid bundle %s: the archive is empty", path)
+	}
+	return len(r.File), nil
+}
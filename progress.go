@@ -0,0 +1,471 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eventials/go-tus"
+)
+
+const timestampFormat = "2006-01-02 15:04:05"
+
+// reporter receives everything the uploader has to say.
+type reporter interface {
+	Printf(format string, args ...interface{})
+	Progress(name string, upload tus.Upload)
+	Retry(name string, attempt, attempts int)
+	// Finished is called once the upload of a file succeeded, was skipped or failed
+	Finished(name string, err error)
+	Close()
+}
+
+// console is where the uploader reports to. It is replaced according to the command line flags.
+var console reporter = newPlainReporter(os.Stdout, 0, 0)
+
+// progressThrottle decides which progress notifications are reported, by time and/or percentage.
+type progressThrottle struct {
+	interval time.Duration
+	percent  int64
+
+	lastPrinted map[string]time.Time
+	lastPercent map[string]int64
+}
+
+func newProgressThrottle(interval time.Duration, percent int64) progressThrottle {
+	return progressThrottle{
+		interval:    interval,
+		percent:     percent,
+		lastPrinted: make(map[string]time.Time),
+		lastPercent: make(map[string]int64),
+	}
+}
+
+// due is called with the lock of the reporter held.
+func (t *progressThrottle) due(name string, upload tus.Upload) bool {
+	// Only report when one of the thresholds is reached, and always the last one
+	percent := upload.Progress()
+	lastPercent, ok := t.lastPercent[name]
+	if ok && upload.Finished() && lastPercent == percent {
+		return false // already reported
+	}
+	if ok && !upload.Finished() {
+		dueToTime := t.interval > 0 && time.Since(t.lastPrinted[name]) >= t.interval
+		dueToPercent := t.percent > 0 && percent-lastPercent >= t.percent
+		if (t.interval > 0 || t.percent > 0) && !dueToTime && !dueToPercent {
+			return false
+		}
+	}
+	t.lastPrinted[name] = time.Now()
+	t.lastPercent[name] = percent
+	return true
+}
+
+// plainReporter prints progress lines.
+type plainReporter struct {
+	mu       sync.Mutex
+	out      io.Writer
+	throttle progressThrottle
+}
+
+func newPlainReporter(out io.Writer, interval time.Duration, percent int64) *plainReporter {
+	return &plainReporter{out: out, throttle: newProgressThrottle(interval, percent)}
+}
+
+func (r *plainReporter) Printf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.out, format, args...)
+}
+
+func (r *plainReporter) Progress(name string, upload tus.Upload) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if totals != nil {
+		totals.update(name, upload.Offset())
+	}
+	if !r.throttle.due(name, upload) {
+		return
+	}
+	fmt.Fprintf(r.out, "%s Completed %v%% %v Bytes of %v Bytes of %s\n",
+		time.Now().Format(timestampFormat),
+		upload.Progress(),
+		upload.Offset(),
+		upload.Size(),
+		name)
+	if totals != nil {
+		fmt.Fprintf(r.out, "%s %s\n", time.Now().Format(timestampFormat), totals)
+	}
+}
+
+func (r *plainReporter) Retry(name string, attempt, attempts int) {
+	r.Printf("%s Attempt %v of %v\n", time.Now().Format(timestampFormat), attempt, attempts)
+}
+
+func (r *plainReporter) Finished(name string, err error) {}
+
+func (r *plainReporter) Close() {}
+
+// jsonReporter writes one JSON object per line, for log collectors.
+type jsonReporter struct {
+	mu       sync.Mutex
+	throttle progressThrottle
+	encoder  *json.Encoder
+}
+
+func newJSONReporter(out io.Writer, interval time.Duration, percent int64) *jsonReporter {
+	return &jsonReporter{
+		throttle: newProgressThrottle(interval, percent),
+		encoder:  json.NewEncoder(out),
+	}
+}
+
+func (r *jsonReporter) log(level, msg string, fields map[string]interface{}) {
+	entry := map[string]interface{}{
+		"time":  time.Now().UTC().Format(time.RFC3339Nano),
+		"level": level,
+		"msg":   msg,
+	}
+	for k, v := range fields {
+		entry[k] = v
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.encoder.Encode(entry)
+}
+
+func (r *jsonReporter) Printf(format string, args ...interface{}) {
+	for _, line := range strings.Split(strings.TrimRight(fmt.Sprintf(format, args...), "\n"), "\n") {
+		level := "info"
+		if strings.HasPrefix(line, "Error") || strings.HasPrefix(line, "Failed") {
+			level = "error"
+		} else if strings.HasPrefix(line, "Warning") {
+			level = "warning"
+		}
+		r.log(level, line, nil)
+	}
+}
+
+func (r *jsonReporter) Progress(name string, upload tus.Upload) {
+	r.mu.Lock()
+	if totals != nil {
+		totals.update(name, upload.Offset())
+	}
+	due := r.throttle.due(name, upload)
+	r.mu.Unlock()
+	if !due {
+		return
+	}
+	fields := map[string]interface{}{
+		"file":    name,
+		"offset":  upload.Offset(),
+		"size":    upload.Size(),
+		"percent": upload.Progress(),
+	}
+	if totals != nil {
+		fields["total"] = totals.String()
+	}
+	r.log("info", "progress", fields)
+}
+
+func (r *jsonReporter) Retry(name string, attempt, attempts int) {
+	r.log("warning", "retry", map[string]interface{}{"file": name, "attempt": attempt, "attempts": attempts})
+}
+
+func (r *jsonReporter) Finished(name string, err error) {
+	if err != nil {
+		r.log("error", "failed", map[string]interface{}{"file": name, "error": err.Error()})
+		return
+	}
+	r.log("info", "finished", map[string]interface{}{"file": name})
+}
+
+func (r *jsonReporter) Close() {}
+
+// tuiReporter redraws a live dashboard of the in-flight uploads with a pane of the latest log lines.
+type tuiReporter struct {
+	mu     sync.Mutex
+	out    io.Writer
+	order  []string
+	files  map[string]*tuiFile
+	logs   []string
+	done   chan struct{}
+	once   sync.Once
+	closed sync.WaitGroup
+}
+
+type tuiFile struct {
+	started time.Time
+	offset  int64
+	size    int64
+	retries int
+	status  string
+}
+
+const (
+	tuiLogLines = 10
+	tuiBarWidth = 40
+)
+
+func newTUIReporter(out io.Writer) *tuiReporter {
+	r := &tuiReporter{
+		out:   out,
+		files: make(map[string]*tuiFile),
+		done:  make(chan struct{}),
+	}
+	r.closed.Add(1)
+	go func() {
+		defer r.closed.Done()
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.render()
+			case <-r.done:
+				r.render()
+				return
+			}
+		}
+	}()
+	return r
+}
+
+func (r *tuiReporter) file(name string) *tuiFile {
+	f, ok := r.files[name]
+	if !ok {
+		f = &tuiFile{started: time.Now()}
+		r.files[name] = f
+		r.order = append(r.order, name)
+	}
+	return f
+}
+
+func (r *tuiReporter) Printf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// once closed, eg for the error of the run, the dashboard is no longer redrawn
+	select {
+	case <-r.done:
+		fmt.Fprintf(r.out, format, args...)
+		return
+	default:
+	}
+	for _, line := range strings.Split(strings.TrimRight(fmt.Sprintf(format, args...), "\n"), "\n") {
+		r.logs = append(r.logs, line)
+	}
+	if len(r.logs) > tuiLogLines {
+		r.logs = r.logs[len(r.logs)-tuiLogLines:]
+	}
+}
+
+func (r *tuiReporter) Progress(name string, upload tus.Upload) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.file(name)
+	f.offset = upload.Offset()
+	f.size = upload.Size()
+	if totals != nil {
+		totals.update(name, upload.Offset())
+	}
+}
+
+func (r *tuiReporter) Retry(name string, attempt, attempts int) {
+	r.mu.Lock()
+	r.file(name).retries = attempt - 1
+	r.mu.Unlock()
+	r.Printf("%s %s: attempt %v of %v", time.Now().Format(timestampFormat), name, attempt, attempts)
+}
+
+func (r *tuiReporter) Finished(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.file(name)
+	f.status = "done"
+	if err != nil {
+		f.status = "FAILED"
+	}
+}
+
+func (r *tuiReporter) Close() {
+	r.once.Do(func() { close(r.done) })
+	r.closed.Wait()
+}
+
+func (r *tuiReporter) render() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J") // move home and clear the screen
+	fmt.Fprintf(&b, "tus-uploader %s - %d upload(s)\n", version, len(r.order))
+	if totals != nil {
+		b.WriteString(totals.String() + "\n")
+	}
+	b.WriteString("\n")
+	for _, name := range r.order {
+		f := r.files[name]
+		var ratio float64
+		if f.size > 0 {
+			ratio = float64(f.offset) / float64(f.size)
+		}
+		filled := int(ratio * tuiBarWidth)
+		rate := float64(f.offset) / time.Since(f.started).Seconds()
+		eta := "-"
+		if rate > 0 && f.offset < f.size {
+			eta = (time.Duration(float64(f.size-f.offset)/rate) * time.Second).Round(time.Second).String()
+		}
+		fmt.Fprintf(&b, "%s %s\n  [%s%s] %5.1f%% %8.2f MB/s  ETA %-8s retries %d\n",
+			name, f.status,
+			strings.Repeat("#", filled), strings.Repeat(" ", tuiBarWidth-filled),
+			ratio*100, rate/1000/1000, eta, f.retries)
+	}
+	b.WriteString("\n--- log ---\n")
+	for _, line := range r.logs {
+		b.WriteString(line + "\n")
+	}
+	io.WriteString(r.out, b.String())
+}
+
+// batchProgress is the combined progress of all the files of a run.
+type batchProgress struct {
+	mu         sync.Mutex
+	started    time.Time
+	totalBytes int64
+	files      int
+	offsets    map[string]int64
+	completed  map[string]bool
+	failed     int
+}
+
+// totals is set when several files are uploaded.
+var totals *batchProgress
+
+func newBatchProgress(sizes map[string]int64) *batchProgress {
+	b := &batchProgress{
+		started:   time.Now(),
+		files:     len(sizes),
+		offsets:   make(map[string]int64),
+		completed: make(map[string]bool),
+	}
+	for _, size := range sizes {
+		b.totalBytes += size
+	}
+	return b
+}
+
+func (b *batchProgress) update(name string, offset int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.offsets[name] = offset
+}
+
+// complete marks a file as done, uploaded or skipped.
+func (b *batchProgress) complete(name string, size int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.offsets[name] = size
+	b.completed[name] = true
+}
+
+func (b *batchProgress) fail(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failed++
+}
+
+func (b *batchProgress) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var done int64
+	for _, offset := range b.offsets {
+		done += offset
+	}
+	var percent int64
+	if b.totalBytes > 0 {
+		percent = done * 100 / b.totalBytes
+	}
+	eta := "-"
+	if elapsed := time.Since(b.started).Seconds(); done > 0 && done < b.totalBytes {
+		rate := float64(done) / elapsed
+		eta = (time.Duration(float64(b.totalBytes-done)/rate) * time.Second).Round(time.Second).String()
+	}
+	remaining := b.files - len(b.completed) - b.failed
+	summary := fmt.Sprintf("Total %v%% %v Bytes of %v Bytes, %d of %d files done, %d remaining", percent, done, b.totalBytes, len(b.completed), b.files, remaining)
+	if b.failed > 0 {
+		summary += fmt.Sprintf(", %d failed", b.failed)
+	}
+	return summary + ", ETA " + eta
+}
+
+// isTerminal tells whether stdout is a terminal rather than a pipe or a file.
+func isTerminal() bool {
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// barReporter redraws a progress bar on the last line of the terminal.
+type barReporter struct {
+	mu   sync.Mutex
+	out  io.Writer
+	line string
+}
+
+func (r *barReporter) Printf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	io.WriteString(r.out, "\r\033[K")
+	fmt.Fprintf(r.out, format, args...)
+	io.WriteString(r.out, r.line)
+}
+
+func (r *barReporter) Progress(name string, upload tus.Upload) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if totals != nil {
+		totals.update(name, upload.Offset())
+	}
+	var ratio float64
+	if upload.Size() > 0 {
+		ratio = float64(upload.Offset()) / float64(upload.Size())
+	}
+	filled := int(ratio * tuiBarWidth)
+	r.line = fmt.Sprintf("[%s%s] %3d%% %s", strings.Repeat("#", filled), strings.Repeat(" ", tuiBarWidth-filled), upload.Progress(), name)
+	if totals != nil {
+		r.line += " | " + totals.String()
+	}
+	io.WriteString(r.out, "\r\033[K"+r.line)
+}
+
+func (r *barReporter) Retry(name string, attempt, attempts int) {
+	r.Printf("%s Attempt %v of %v\n", time.Now().Format(timestampFormat), attempt, attempts)
+}
+
+func (r *barReporter) Finished(name string, err error) {}
+
+func (r *barReporter) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.line != "" {
+		io.WriteString(r.out, "\r\033[K"+r.line+"\n")
+		r.line = ""
+	}
+}
+
+// quietReporter prints the messages but not the progress.
+type quietReporter struct {
+	*plainReporter
+}
+
+func (r *quietReporter) Progress(name string, upload tus.Upload) {
+	if totals != nil {
+		totals.update(name, upload.Offset())
+	}
+}
+
+func (r *quietReporter) Retry(name string, attempt, attempts int) {}
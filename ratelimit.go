@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateWindow limits the upload rate between two times of the day, in minutes since midnight.
+// A window whose end is before its start spans midnight and a window whose start is its end lasts all day.
+type rateWindow struct {
+	start, end int
+	// bytesPerSecond is 0 for no limit
+	bytesPerSecond int64
+}
+
+func (w rateWindow) contains(minute int) bool {
+	switch {
+	case w.start < w.end:
+		return minute >= w.start && minute < w.end
+	case w.start > w.end:
+		return minute >= w.start || minute < w.end
+	default:
+		return true
+	}
+}
+
+// parseRateSchedule parses windows like "08:00-18:00=5M,18:00-08:00=0" where the rate is in Bytes per second.
+func parseRateSchedule(value string) ([]rateWindow, error) {
+	var windows []rateWindow
+	for _, tok := range strings.Split(value, ",") {
+		tok = strings.TrimSpace(tok)
+		if tok == "" {
+			continue
+		}
+		invalid := fmt.Errorf("Invalid --limit-rate-schedule window '%s'. eg: 08:00-18:00=5M", tok)
+		toks := strings.SplitN(tok, "=", 2)
+		if len(toks) != 2 {
+			return nil, invalid
+		}
+		times := strings.SplitN(toks[0], "-", 2)
+		if len(times) != 2 {
+			return nil, invalid
+		}
+		var window rateWindow
+		var err error
+		if window.start, err = parseTimeOfDay(times[0]); err != nil {
+			return nil, invalid
+		}
+		if window.end, err = parseTimeOfDay(times[1]); err != nil {
+			return nil, invalid
+		}
+		if window.bytesPerSecond, err = parseSize(toks[1]); err != nil {
+			return nil, invalid
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+func parseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		if strings.TrimSpace(value) == "24:00" {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// rateLimiter shares the rate of the current window of the schedule between the chunks of all the uploads.
+type rateLimiter struct {
+	schedule []rateWindow
+	mu       sync.Mutex
+	// next is when the Bytes sent so far are paid for
+	next time.Time
+}
+
+// rate returns the limit in Bytes per second at t, 0 for no limit. The first matching window wins.
+func (l *rateLimiter) rate(t time.Time) int64 {
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range l.schedule {
+		if window.contains(minute) {
+			return window.bytesPerSecond
+		}
+	}
+	return 0
+}
+
+// wait delays the sending of n Bytes to stay under the current rate.
+func (l *rateLimiter) wait(n int) error {
+	now := time.Now()
+	rate := l.rate(now)
+	if rate == 0 || n == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(rate) * float64(time.Second)))
+	d := l.next.Sub(now)
+	l.mu.Unlock()
+	return sleep(d)
+}
+
+// throttledBody reads the body of a chunk at the rate of the limiter.
+type throttledBody struct {
+	io.ReadCloser
+	limiter *rateLimiter
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	// Small reads keep the rate smooth
+	if len(p) > 32*1024 {
+		p = p[:32*1024]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if werr := b.limiter.wait(n); werr != nil {
+		return n, werr
+	}
+	return n, err
+}
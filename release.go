@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// releaseBundle is a bundle of a --release-manifest. eg:
+//
+//	bundles:
+//	  - name: base
+//	    file: Infoblox.zip
+//	  - name: extension
+//	    file: Infoblox-extension.zip
+//	    dependsOn: [base]
+type releaseBundle struct {
+	Name      string   `json:"name" yaml:"name"`
+	File      string   `json:"file" yaml:"file"`
+	DependsOn []string `json:"dependsOn" yaml:"dependsOn"`
+}
+
+// loadReleaseManifest loads the bundles of a release manifest in the order of their dependencies.
+// The files are relative to the directory of the manifest. The JSON manifests are parsed as YAML.
+func loadReleaseManifest(path string) ([]releaseBundle, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := struct {
+		Bundles []releaseBundle `yaml:"bundles"`
+	}{}
+	if err := yaml.UnmarshalStrict(content, &raw); err != nil {
+		return nil, fmt.Errorf("Invalid release manifest %s: %s", path, err.Error())
+	}
+	if len(raw.Bundles) == 0 {
+		return nil, fmt.Errorf("Invalid release manifest %s: no bundles", path)
+	}
+	byName := make(map[string]int, len(raw.Bundles))
+	for i, bundle := range raw.Bundles {
+		if bundle.Name == "" || bundle.File == "" {
+			return nil, fmt.Errorf("Invalid release manifest %s: the bundle %d must have a name and a file", path, i+1)
+		}
+		if _, ok := byName[bundle.Name]; ok {
+			return nil, fmt.Errorf("Invalid release manifest %s: the bundle '%s' is declared twice", path, bundle.Name)
+		}
+		byName[bundle.Name] = i
+		if !filepath.IsAbs(bundle.File) {
+			raw.Bundles[i].File = filepath.Join(filepath.Dir(path), bundle.File)
+		}
+		if _, err := os.Stat(raw.Bundles[i].File); err != nil {
+			return nil, fmt.Errorf("Invalid release manifest %s: the file of the bundle '%s': %s", path, bundle.Name, err.Error())
+		}
+	}
+	for _, bundle := range raw.Bundles {
+		for _, dependency := range bundle.DependsOn {
+			if _, ok := byName[dependency]; !ok {
+				return nil, fmt.Errorf("Invalid release manifest %s: the bundle '%s' depends on the unknown bundle '%s'", path, bundle.Name, dependency)
+			}
+		}
+	}
+
+	// Take the first bundle of the manifest with all its dependencies taken, keeping the order of the manifest
+	// as much as possible
+	ordered := make([]releaseBundle, 0, len(raw.Bundles))
+	done := make(map[string]bool, len(raw.Bundles))
+	for len(ordered) < len(raw.Bundles) {
+		progressed := false
+		for _, bundle := range raw.Bundles {
+			if done[bundle.Name] {
+				continue
+			}
+			ready := true
+			for _, dependency := range bundle.DependsOn {
+				ready = ready && done[dependency]
+			}
+			if ready {
+				ordered = append(ordered, bundle)
+				done[bundle.Name] = true
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			var cycle []string
+			for _, bundle := range raw.Bundles {
+				if !done[bundle.Name] {
+					cycle = append(cycle, bundle.Name)
+				}
+			}
+			return nil, fmt.Errorf("Invalid release manifest %s: circular dependencies between %s", path, strings.Join(cycle, ", "))
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadReleaseManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"base.zip", "extension.zip", "other.zip"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		name     string
+		manifest string
+		order    []string
+		err      string
+	}{
+		{"manifest order", `
+bundles:
+  - {name: base, file: base.zip}
+  - {name: other, file: other.zip}
+`, []string{"base", "other"}, ""},
+		{"dependency declared after", `
+bundles:
+  - {name: extension, file: extension.zip, dependsOn: [base]}
+  - {name: base, file: base.zip}
+  - {name: other, file: other.zip}
+`, []string{"base", "extension", "other"}, ""},
+		{"chain of dependencies", `
+bundles:
+  - {name: other, file: other.zip, dependsOn: [extension]}
+  - {name: extension, file: extension.zip, dependsOn: [base]}
+  - {name: base, file: base.zip}
+`, []string{"base", "extension", "other"}, ""},
+		{"JSON manifest", `{"bundles": [{"name": "extension", "file": "extension.zip", "dependsOn": ["base"]}, {"name": "base", "file": "base.zip"}]}`,
+			[]string{"base", "extension"}, ""},
+		{"cycle", `
+bundles:
+  - {name: base, file: base.zip}
+  - {name: extension, file: extension.zip, dependsOn: [other]}
+  - {name: other, file: other.zip, dependsOn: [extension]}
+`, nil, "circular dependencies between extension, other"},
+		{"self dependency", `
+bundles:
+  - {name: base, file: base.zip, dependsOn: [base]}
+`, nil, "circular dependencies between base"},
+		{"unknown dependency", `
+bundles:
+  - {name: extension, file: extension.zip, dependsOn: [core]}
+`, nil, "the bundle 'extension' depends on the unknown bundle 'core'"},
+		{"declared twice", `
+bundles:
+  - {name: base, file: base.zip}
+  - {name: base, file: other.zip}
+`, nil, "the bundle 'base' is declared twice"},
+		{"missing file", `
+bundles:
+  - {name: base, file: missing.zip}
+`, nil, "the file of the bundle 'base'"},
+		{"no name", `
+bundles:
+  - {file: base.zip}
+`, nil, "the bundle 1 must have a name and a file"},
+		{"no bundles", `bundles: []`, nil, "no bundles"},
+		{"unknown field", `
+bundles:
+  - {name: base, file: base.zip, dependOn: [other]}
+`, nil, "field dependOn not found"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, "release.yaml")
+			if err := ioutil.WriteFile(path, []byte(test.manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+			bundles, err := loadReleaseManifest(path)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error %v instead of %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var order []string
+			for _, bundle := range bundles {
+				order = append(order, bundle.Name)
+				if bundle.File != filepath.Join(dir, bundle.Name+".zip") {
+					t.Errorf("the file of the bundle %s is %s", bundle.Name, bundle.File)
+				}
+			}
+			if strings.Join(order, ",") != strings.Join(test.order, ",") {
+				t.Errorf("order %v instead of %v", order, test.order)
+			}
+		})
+	}
+}
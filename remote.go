@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eventials/go-tus"
+	"github.com/spf13/cobra"
+)
+
+// addConnectionFlags registers the flags of the subcommands that talk to an existing upload.
+func addConnectionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("header", nil, "Extra header, can be repeated. A value starting with @ is read from a file. eg: 'X-Token: @/path/to/file'")
+	cmd.Flags().StringArray("header-from-env", nil, "Extra header with its value read from an environment variable, can be repeated. eg: 'X-Api-Key=MY_SECRET_ENV'")
+	cmd.Flags().Bool("skip-ssl-verification", false, "Set to true to skip the validation of the TLS certificates")
+	cmd.Flags().String("vra-username", "", "VRA Username")
+	cmd.Flags().String("vra-password", "", "VRA Password")
+	addClientAuthFlags(cmd)
+	addTrustFlags(cmd)
+	cmd.Flags().String("user-agent", defaultUserAgent(), "User-Agent sent with every request")
+	cmd.Flags().String("hmac-key", "", "Shared secret signing every request with HMAC-SHA256. A value starting with @ is read from a file")
+	cmd.Flags().String("hmac-header", "X-Signature", "Header of the --hmac-key signature")
+}
+
+// connect creates a tus client for uploadURL from the connection flags and logs in vRA when requested.
+func connect(cmd *cobra.Command, uploadURL string) (*tus.Client, error) {
+	opts, err := parseConnectionOptions(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if !isURL(uploadURL) {
+		return nil, fmt.Errorf("Invalid upload url '%s'", uploadURL)
+	}
+
+	clientConfig := tus.DefaultConfig()
+	clientConfig.Header = opts.httpHeaders
+	clientConfig.HttpClient = newHTTPClient(opts)
+	client, err := tus.NewClient(uploadURL, clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	if bearerToken != "" {
+		opts.httpHeaders.Set("Authorization", "Bearer "+bearerToken)
+	} else if opts.vraUser != "" || opts.clientID != "" {
+		token, err := vraLogin(opts, client, clientConfig)
+		if err != nil {
+			return nil, err
+		}
+		opts.httpHeaders.Set("Authorization", "Bearer "+token)
+	}
+	return client, nil
+}
+
+func parseConnectionOptions(cmd *cobra.Command) (*options, error) {
+	opts := &options{}
+	headers, err := cmd.Flags().GetStringArray("header")
+	if err != nil {
+		return nil, err
+	}
+	if opts.httpHeaders, err = parseHeaders(headers); err != nil {
+		return nil, err
+	}
+	if opts.headerTemplates, err = splitHeaderTemplates(opts.httpHeaders); err != nil {
+		return nil, err
+	}
+	headersFromEnv, err := cmd.Flags().GetStringArray("header-from-env")
+	if err != nil {
+		return nil, err
+	}
+	if opts.secretHeaders, err = addHeadersFromEnv(opts.httpHeaders, headersFromEnv); err != nil {
+		return nil, err
+	}
+	if opts.skipTLSVerification, err = cmd.Flags().GetBool("skip-ssl-verification"); err != nil {
+		return nil, err
+	}
+	if opts.vraUser, err = cmd.Flags().GetString("vra-username"); err != nil {
+		return nil, err
+	}
+	if opts.vraPassword, err = cmd.Flags().GetString("vra-password"); err != nil {
+		return nil, err
+	}
+	if err := parseClientAuth(cmd, opts); err != nil {
+		return nil, err
+	}
+	if err := parseTrust(cmd, opts); err != nil {
+		return nil, err
+	}
+	if opts.userAgent, err = cmd.Flags().GetString("user-agent"); err != nil {
+		return nil, err
+	}
+	hmacKey, err := cmd.Flags().GetString("hmac-key")
+	if err != nil {
+		return nil, err
+	}
+	if opts.hmacKey, err = loadHMACKey(hmacKey); err != nil {
+		return nil, err
+	}
+	if opts.hmacHeader, err = cmd.Flags().GetString("hmac-header"); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// uploadInfo is the state of an upload reported by a HEAD request.
+type uploadInfo struct {
+	Offset int64
+	// Length is -1 when the length of the upload is deferred
+	Length   int64
+	Metadata map[string]string
+	// Expires is the Upload-Expires of the expiration extension, empty when the server does not report it
+	Expires string
+}
+
+// headUpload fetches the state of an existing upload.
+func headUpload(client *tus.Client, uploadURL string) (*uploadInfo, error) {
+	req, err := http.NewRequest("HEAD", uploadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case 200:
+	case 403, 404, 410:
+		return nil, fmt.Errorf("Upload %s not found: %s", uploadURL, res.Status)
+	default:
+		return nil, fmt.Errorf("Unexpected status of the upload %s: %s", uploadURL, res.Status)
+	}
+
+	info := &uploadInfo{Length: -1, Expires: res.Header.Get("Upload-Expires")}
+	if info.Offset, err = strconv.ParseInt(res.Header.Get("Upload-Offset"), 10, 64); err != nil {
+		return nil, fmt.Errorf("Invalid Upload-Offset '%s' of the upload %s", res.Header.Get("Upload-Offset"), uploadURL)
+	}
+	if length := res.Header.Get("Upload-Length"); length != "" {
+		if info.Length, err = strconv.ParseInt(length, 10, 64); err != nil {
+			return nil, fmt.Errorf("Invalid Upload-Length '%s' of the upload %s", length, uploadURL)
+		}
+	}
+	if info.Metadata, err = parseUploadMetadata(res.Header.Get("Upload-Metadata")); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma separated keys and base64 values.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		toks := strings.Fields(pair)
+		switch len(toks) {
+		case 0:
+		case 1:
+			metadata[toks[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(toks[1])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid Upload-Metadata value of '%s': %s", toks[0], err.Error())
+			}
+			metadata[toks[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("Invalid Upload-Metadata '%s'", pair)
+		}
+	}
+	return metadata, nil
+}
+
+// verifyUploadOffset checks that the server received size Bytes, catching a truncation by an intermediary.
+func verifyUploadOffset(client *tus.Client, uploadURL string, size int64) error {
+	info, err := headUpload(client, uploadURL)
+	if err != nil {
+		return fmt.Errorf("Unable to verify the offset of the upload: %s", err.Error())
+	}
+	if info.Offset != size {
+		return fmt.Errorf("The server reports an offset of %d Bytes for %s instead of the %d Bytes uploaded", info.Offset, uploadURL, size)
+	}
+	return nil
+}
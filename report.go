@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// uploadResult is the outcome of the upload of one file.
+type uploadResult struct {
+	Environment     string        `json:"environment,omitempty"`
+	File            string        `json:"file"`
+	Target          string        `json:"target"`
+	Size            int64         `json:"size"`
+	Duration        time.Duration `json:"-"`
+	Attempts        int           `json:"attempts"`
+	UploadURL       string        `json:"uploadUrl,omitempty"`
+	BundleID        string        `json:"bundleId,omitempty"`
+	ManifestURL     string        `json:"manifestUrl,omitempty"`
+	Status          string        `json:"status"`
+	ImportStatus    string        `json:"importStatus,omitempty"`
+	ProviderName    string        `json:"providerName,omitempty"`
+	ProviderVersion string        `json:"providerVersion,omitempty"`
+	IntegrationsURL string        `json:"integrationsUrl,omitempty"`
+	Error           string        `json:"error,omitempty"`
+}
+
+func (r uploadResult) MarshalJSON() ([]byte, error) {
+	type plain uploadResult
+	return json.Marshal(struct {
+		plain
+		DurationSeconds float64 `json:"durationSeconds"`
+	}{plain(r), r.Duration.Seconds()})
+}
+
+// writeBatchReport writes the per-file results of a run as csv or json.
+func writeBatchReport(w io.Writer, format string, results []*uploadResult) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"file", "target", "size", "duration_seconds", "attempts", "upload_url", "bundle_id", "status", "import_status", "provider_name", "provider_version", "error", "manifest_url", "integrations_url", "environment"})
+		for _, r := range results {
+			writer.Write([]string{
+				r.File,
+				r.Target,
+				strconv.FormatInt(r.Size, 10),
+				strconv.FormatFloat(r.Duration.Seconds(), 'f', 3, 64),
+				strconv.Itoa(r.Attempts),
+				r.UploadURL,
+				r.BundleID,
+				r.Status,
+				r.ImportStatus,
+				r.ProviderName,
+				r.ProviderVersion,
+				r.Error,
+				r.ManifestURL,
+				r.IntegrationsURL,
+				r.Environment,
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("Invalid --batch-report '%s'. It must be csv or json", format)
+}
+
+// parseOutputTemplate parses the --output-template, checking it against the fields of an uploadResult.
+func parseOutputTemplate(text string) (*template.Template, error) {
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	tmpl, err := template.New("output").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid --output-template: %s", err.Error())
+	}
+	if err := tmpl.Execute(ioutil.Discard, &uploadResult{}); err != nil {
+		return nil, fmt.Errorf("Invalid --output-template: %s", err.Error())
+	}
+	return tmpl, nil
+}
+
+// writeTemplateReport writes the result of each file with the --output-template.
+func writeTemplateReport(w io.Writer, tmpl *template.Template, results []*uploadResult) error {
+	for _, r := range results {
+		if err := tmpl.Execute(w, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resumeStore is a tus.Store persisted in a JSON file: the url of the unfinished uploads keyed by their fingerprint.
+// A retry, a run paused then killed or an interrupted run resume the upload from the offset of the server.
+type resumeStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func defaultResumeStorePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "tus-uploader", "uploads.json")
+}
+
+func openResumeStore(path string) *resumeStore {
+	if path == "" {
+		return nil
+	}
+	return &resumeStore{path: path}
+}
+
+// resumeFingerprint identifies the upload of a version of a file to a target.
+func resumeFingerprint(file, target string, fi os.FileInfo) (string, error) {
+	key, err := journalKey(file, target)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %d %s", key, fi.Size(), fi.ModTime().UTC().Format(time.RFC3339Nano)), nil
+}
+
+func (s *resumeStore) load() (map[string]string, error) {
+	urls := make(map[string]string)
+	content, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return urls, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &urls); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+func (s *resumeStore) update(change func(urls map[string]string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	urls, err := s.load()
+	if err == nil {
+		change(urls)
+		err = writeJSONFile(s.path, urls)
+	}
+	if err != nil {
+		console.Printf("Warning: unable to update the resume store %s: %s\n", s.path, err.Error())
+	}
+}
+
+func (s *resumeStore) Get(fingerprint string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	urls, err := s.load()
+	if err != nil {
+		console.Printf("Warning: unable to read the resume store %s: %s\n", s.path, err.Error())
+		return "", false
+	}
+	url, ok := urls[fingerprint]
+	return url, ok
+}
+
+func (s *resumeStore) Set(fingerprint, url string) {
+	s.update(func(urls map[string]string) { urls[fingerprint] = url })
+}
+
+func (s *resumeStore) Delete(fingerprint string) {
+	s.update(func(urls map[string]string) { delete(urls, fingerprint) })
+}
+
+func (s *resumeStore) Close() {}
+
+// writeJSONFile replaces a file atomically with the JSON of v.
+func writeJSONFile(path string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// confirmResumeOffset asks for the confirmation of a --resume-offset on a terminal.
+// A wrong offset corrupts the upload: the server appends the chunks wherever its own offset is.
+func confirmResumeOffset(file, uploadURL string, offset int64, yes bool) error {
+	if yes {
+		return nil
+	}
+	fi, err := os.Stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("--resume-offset requires --yes when the input is not a terminal")
+	}
+	fmt.Fprintf(os.Stderr, "Resume the upload of %s to %s at %d Bytes regardless of the offset reported by the server? [y/N] ", file, uploadURL, offset)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return fmt.Errorf("Cancelled the upload of %s", file)
+	}
+	return nil
+}
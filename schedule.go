@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+var startAtLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// parseStartAt parses a date and time, in the local time zone unless specified,
+// or a time of day which is the next occurrence of that time. eg: 2024-06-01T02:00Z or 02:00
+func parseStartAt(value string, now time.Time) (time.Time, error) {
+	for _, layout := range startAtLayouts {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			start := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+			if start.Before(now) {
+				start = start.AddDate(0, 0, 1)
+			}
+			return start, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid --start-at '%s'. eg: 2024-06-01T02:00Z, '2024-06-01 02:00' or 02:00", value)
+}
+
+// waitUntil sleeps until the start of the uploads unless the run is interrupted.
+func waitUntil(start time.Time) error {
+	d := time.Until(start)
+	if d <= 0 {
+		return nil
+	}
+	console.Printf("Waiting until %s to start the uploads\n", start.Format(timestampFormat+" MST"))
+	return sleep(d)
+}
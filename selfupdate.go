@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	releasesURL        = "https://api.github.com/repos/hmalphettes/tus-vra-uploader/releases/latest"
+	checksumsAssetName = "checksums.txt"
+)
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (r *githubRelease) assetURL(name string) string {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset.BrowserDownloadURL
+		}
+	}
+	return ""
+}
+
+func newSelfUpdateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Replace this binary with the latest release published on GitHub",
+		Args:  cobra.NoArgs,
+		RunE:  selfUpdate,
+	}
+	cmd.Flags().Bool("check", false, "Only report whether a newer release is available")
+	cmd.Flags().Bool("force", false, "Install the latest release even if it is not newer than this binary")
+	return cmd
+}
+
+func selfUpdate(cmd *cobra.Command, args []string) error {
+	checkOnly, err := cmd.Flags().GetBool("check")
+	if err != nil {
+		return err
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+
+	release, err := latestRelease()
+	if err != nil {
+		return err
+	}
+	if !force && !isNewerVersion(release.TagName, version) {
+		fmt.Printf("tus-uploader %s is up to date (latest release is %s)\n", version, release.TagName)
+		return nil
+	}
+	if checkOnly {
+		fmt.Printf("A newer release is available: %s (this binary is %s)\n", release.TagName, version)
+		return nil
+	}
+
+	assetName := releaseAssetName()
+	if assetName == "" {
+		return fmt.Errorf("No release binary is published for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	binaryURL := release.assetURL(assetName)
+	if binaryURL == "" {
+		return fmt.Errorf("Release %s does not contain %s", release.TagName, assetName)
+	}
+	checksumsURL := release.assetURL(checksumsAssetName)
+	if checksumsURL == "" {
+		return fmt.Errorf("Release %s does not contain %s: refusing to install an unverified binary", release.TagName, checksumsAssetName)
+	}
+
+	checksums, err := download(checksumsURL)
+	if err != nil {
+		return err
+	}
+	expected, err := findChecksum(checksums, assetName)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Downloading %s\n", binaryURL)
+	binary, err := download(binaryURL)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(binary)
+	if actual := hex.EncodeToString(digest[:]); actual != expected {
+		return fmt.Errorf("Checksum mismatch for %s: expected %s but got %s", assetName, expected, actual)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return err
+	}
+	if err := replaceExecutable(executable, binary); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s from %s to %s\n", executable, version, release.TagName)
+	return nil
+}
+
+func latestRelease() (*githubRelease, error) {
+	body, err := download(releasesURL)
+	if err != nil {
+		return nil, err
+	}
+	release := &githubRelease{}
+	if err := json.Unmarshal(body, release); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+func download(url string) ([]byte, error) {
+	response, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("Failed to download %s. StatusCode was '%s' instead of 200/OK", url, response.Status)
+	}
+	return body, nil
+}
+
+// releaseAssetName returns the name of the binary built by the Makefile for this platform.
+func releaseAssetName() string {
+	switch {
+	case runtime.GOOS == "windows":
+		return "tus-vra-uploader.exe"
+	case runtime.GOOS == "linux" && runtime.GOARCH == "amd64":
+		return "tus-vra-uploader-linux64"
+	case runtime.GOOS == "darwin":
+		return "tus-vra-uploader-darwin"
+	}
+	return ""
+}
+
+// findChecksum looks up a file in the output of sha256sum.
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("No checksum found for %s in %s", name, checksumsAssetName)
+}
+
+// replaceExecutable swaps the running binary with a new one.
+// The old binary is renamed first as windows does not allow overwriting a running executable.
+func replaceExecutable(executable string, binary []byte) error {
+	dir := filepath.Dir(executable)
+	tmp, err := ioutil.TempFile(dir, ".tus-uploader-update-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, bytes.NewReader(binary)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+
+	old := executable + ".old"
+	os.Remove(old)
+	if err := os.Rename(executable, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), executable); err != nil {
+		os.Rename(old, executable)
+		return err
+	}
+	os.Remove(old) // fails on windows while running: cleaned up on the next update
+	return nil
+}
+
+// isNewerVersion compares dotted versions such as v1.2.3.
+// A development build is always considered older than a release.
+func isNewerVersion(latest, current string) bool {
+	if current == "dev" {
+		return true
+	}
+	l := versionNumbers(latest)
+	c := versionNumbers(current)
+	for i := 0; i < len(l) || i < len(c); i++ {
+		var lv, cv int
+		if i < len(l) {
+			lv = l[i]
+		}
+		if i < len(c) {
+			cv = c[i]
+		}
+		if lv != cv {
+			return lv > cv
+		}
+	}
+	return false
+}
+
+func versionNumbers(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var numbers []int
+	for _, tok := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(tok)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var (
+	errInterrupted = errors.New("Interrupted")
+	// interrupted is closed on the first SIGINT or SIGTERM
+	interrupted = make(chan struct{})
+	// exitSignal is the signal that interrupted the run
+	exitSignal os.Signal
+)
+
+// handleSignals stops the uploads cleanly after their current chunk on SIGINT or SIGTERM so that
+// the lock files and the staged files are removed. A second signal exits immediately.
+// Running as PID 1 in a container, nothing else would handle SIGTERM.
+func handleSignals() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		exitSignal = <-signals
+		console.Printf("Received %s: stopping after the current chunk\n", exitSignal)
+		close(interrupted)
+		<-signals
+		os.Exit(exitCode())
+	}()
+	handlePauseSignals()
+}
+
+func isInterrupted() bool {
+	select {
+	case <-interrupted:
+		return true
+	default:
+		return false
+	}
+}
+
+// exitCode follows the shell convention of 128 + the signal number.
+func exitCode() int {
+	if sig, ok := exitSignal.(syscall.Signal); ok {
+		return 128 + int(sig)
+	}
+	return 1
+}
+
+// sleep waits for d unless the run is interrupted.
+func sleep(d time.Duration) error {
+	select {
+	case <-interrupted:
+		return errInterrupted
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handlePauseSignals pauses the uploads before their next chunk on SIGUSR1 and resumes them on SIGUSR2.
+func handlePauseSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGUSR1 && pause.Pause() {
+				console.Printf("Received %s: pausing the uploads after the current chunk, send SIGUSR2 to resume\n", sig)
+			} else if sig == syscall.SIGUSR2 && pause.Resume() {
+				console.Printf("Received %s: resuming the uploads\n", sig)
+			}
+		}
+	}()
+}
@@ -0,0 +1,4 @@
+package main
+
+// handlePauseSignals is a no-op: there is no SIGUSR1 and SIGUSR2 on Windows.
+func handlePauseSignals() {}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gpgSign writes a detached armored signature of the file to <file>.asc and returns it.
+func gpgSign(program, key, file string) (string, error) {
+	signature := file + ".asc"
+	cmd := exec.Command(program, "--batch", "--yes", "--armor", "--local-user", key, "--output", signature, "--detach-sign", file)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Failed to sign %s with %s: %s %s", file, program, err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	content, err := ioutil.ReadFile(signature)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// detachedSignature returns the path of the detached signature of a file: <file>.asc or <file>.sig.
+func detachedSignature(file string) (string, error) {
+	for _, ext := range []string{".asc", ".sig"} {
+		if _, err := os.Stat(file + ext); err == nil {
+			return file + ext, nil
+		}
+	}
+	return "", fmt.Errorf("No detached signature %s.asc or %s.sig found", file, file)
+}
+
+// gpgVerify checks the detached signature of a file against a keyring of trusted keys exported with gpg --export.
+func gpgVerify(program, keyring, file string) error {
+	signature, err := detachedSignature(file)
+	if err != nil {
+		return err
+	}
+	keyring, err = filepath.Abs(keyring)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(program, "--batch", "--no-default-keyring", "--keyring", keyring, "--trust-model", "always", "--verify", signature, file)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Refusing to upload %s: its signature %s does not validate against %s: %s", file, signature, keyring, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
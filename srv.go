@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	netURL "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const srvDialTimeout = 5 * time.Second
+
+// isSRVTarget tells whether a target is discovered with DNS SRV records:
+// srv://_tus._tcp.example.com/path for https or srv+http://_tus._tcp.example.com/path for http.
+func isSRVTarget(arg string) bool {
+	u, err := netURL.Parse(arg)
+	return err == nil && (u.Scheme == "srv" || u.Scheme == "srv+http") && u.Host != ""
+}
+
+// resolveSRVTarget replaces the SRV name of a target with the host and port of the first reachable record.
+// The records are tried in the order of their priority, randomized by their weight within the same priority.
+func resolveSRVTarget(target string) (string, error) {
+	u, err := netURL.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	scheme := "https"
+	if u.Scheme == "srv+http" {
+		scheme = "http"
+	}
+	_, records, err := net.LookupSRV("", "", u.Hostname())
+	if err != nil {
+		return "", fmt.Errorf("Unable to resolve the SRV records of %s: %s", u.Hostname(), err.Error())
+	}
+	var failures []string
+	for _, record := range records {
+		host := net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+		conn, err := net.DialTimeout("tcp", host, srvDialTimeout)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		conn.Close()
+		u.Scheme = scheme
+		u.Host = host
+		return u.String(), nil
+	}
+	if len(failures) == 0 {
+		return "", fmt.Errorf("No SRV record for %s", u.Hostname())
+	}
+	return "", fmt.Errorf("None of the SRV records of %s is reachable: %s", u.Hostname(), strings.Join(failures, "; "))
+}
@@ -0,0 +1,17 @@
+package main
+
+import "fmt"
+
+// checkStagingSpace fails early when dir does not have the room for a staged file of size Bytes,
+// rather than halfway through writing it. Set TMPDIR to stage the files on another file system.
+func checkStagingSpace(dir string, size int64) error {
+	available, err := availableDiskSpace(dir)
+	if err != nil {
+		// Some file systems do not report their free space: let the write fail if it must
+		return nil
+	}
+	if uint64(size) > available {
+		return fmt.Errorf("Not enough space in %s to stage %d Bytes: %d Bytes available. Set TMPDIR to use another directory", dir, size, available)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status <upload-url>",
+		Short: "Print the offset, length, expiry and metadata of an existing upload",
+		Long: `Sends a HEAD request to an upload to check whether an interrupted transfer can be resumed.
+Exits with 1 when the upload does not exist anymore.`,
+		Args: cobra.ExactArgs(1),
+		RunE: uploadStatus,
+	}
+	addConnectionFlags(cmd)
+	cmd.Flags().Bool("json", false, "Print the status as JSON")
+	return cmd
+}
+
+func uploadStatus(cmd *cobra.Command, args []string) error {
+	uploadURL := args[0]
+	asJSON, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+	client, err := connect(cmd, uploadURL)
+	if err != nil {
+		return err
+	}
+	info, err := headUpload(client, uploadURL)
+	if err != nil {
+		return err
+	}
+
+	state := "incomplete, resumable"
+	if info.Length >= 0 && info.Offset >= info.Length {
+		state = "complete"
+	} else if info.Expires != "" {
+		if expires, err := http.ParseTime(info.Expires); err == nil && expires.Before(time.Now()) {
+			state = "expired"
+		}
+	}
+
+	if asJSON {
+		return json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
+			"url":      uploadURL,
+			"offset":   info.Offset,
+			"length":   info.Length,
+			"expires":  info.Expires,
+			"metadata": info.Metadata,
+			"state":    state,
+		})
+	}
+	fmt.Printf("Upload:   %s\n", uploadURL)
+	fmt.Printf("State:    %s\n", state)
+	fmt.Printf("Offset:   %d Bytes\n", info.Offset)
+	if info.Length >= 0 {
+		percent := int64(100)
+		if info.Length > 0 {
+			percent = 100 * info.Offset / info.Length
+		}
+		fmt.Printf("Length:   %d Bytes (%d%% received)\n", info.Length, percent)
+	} else {
+		fmt.Printf("Length:   deferred\n")
+	}
+	if info.Expires != "" {
+		fmt.Printf("Expires:  %s\n", info.Expires)
+	}
+	for _, k := range sortedKeys(info.Metadata) {
+		fmt.Printf("Metadata: %s=%s\n", k, info.Metadata[k])
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// Kubernetes truncates the termination messages to 4096 Bytes.
+const maxTerminationMessage = 4096
+
+// runResults are the results of the uploads of the run, once they are finished.
+var runResults []*uploadResult
+
+type runOutcome struct {
+	Status  string          `json:"status"`
+	Error   string          `json:"error,omitempty"`
+	Results []*uploadResult `json:"results,omitempty"`
+}
+
+func newRunOutcome(err error) runOutcome {
+	outcome := runOutcome{Status: "succeeded", Results: runResults}
+	if err != nil {
+		outcome.Status = "failed"
+		outcome.Error = err.Error()
+	}
+	return outcome
+}
+
+// writeTerminationMessage writes the outcome of the run where Kubernetes reads the termination message of a container.
+// It is only written when running in Kubernetes or when --termination-log is set explicitly.
+func writeTerminationMessage(cmd *cobra.Command, err error) error {
+	flag := cmd.Flags().Lookup("termination-log")
+	if flag == nil || flag.Value.String() == "" {
+		return nil
+	}
+	if !flag.Changed && os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return nil
+	}
+	outcome := newRunOutcome(err)
+	content, jsonErr := json.Marshal(outcome)
+	if jsonErr != nil {
+		return jsonErr
+	}
+	if len(content) > maxTerminationMessage {
+		outcome.Results = nil
+		if content, jsonErr = json.Marshal(outcome); jsonErr != nil {
+			return jsonErr
+		}
+	}
+	return ioutil.WriteFile(flag.Value.String(), content, 0644)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+)
+
+func defaultUserAgent() string {
+	return fmt.Sprintf("tus-uploader/%s (%s/%s)", version, runtime.GOOS, runtime.GOARCH)
+}
+
+// uploaderTransport decorates every request sent by the uploader:
+// the TUS requests as well as the vRA login and import.
+type uploaderTransport struct {
+	userAgent string
+	// checksumAlgo is set to send the Upload-Checksum of the chunks
+	checksumAlgo string
+	// headers evaluates the templated headers
+	headers *headerTemplater
+	// limiter throttles the chunks on the --limit-rate-schedule
+	limiter *rateLimiter
+	signer  *hmacSigner
+	next    http.RoundTripper
+}
+
+func (t *uploaderTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	if t.userAgent != "" {
+		request.Header.Set("User-Agent", t.userAgent)
+	}
+	if t.headers != nil {
+		if err := t.headers.apply(request); err != nil {
+			return nil, err
+		}
+	}
+	if isChunk(request) {
+		if err := pause.wait(); err != nil {
+			return nil, err
+		}
+	}
+	if t.checksumAlgo != "" && isChunk(request) {
+		if err := setUploadChecksum(request, t.checksumAlgo); err != nil {
+			return nil, err
+		}
+	}
+	if t.signer != nil {
+		if err := t.signer.sign(request); err != nil {
+			return nil, err
+		}
+	}
+	if t.limiter != nil && isChunk(request) {
+		request.Body = &throttledBody{ReadCloser: request.Body, limiter: t.limiter}
+	}
+	return t.next.RoundTrip(request)
+}
+
+// isChunk tells whether the request is a PATCH of upload data.
+func isChunk(request *http.Request) bool {
+	return request.Header.Get("Content-Type") == "application/offset+octet-stream" && request.Body != nil
+}
+
+// readBody reads the body of a request and makes it readable again.
+func readBody(request *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return nil, err
+	}
+	request.Body.Close()
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func setUploadChecksum(request *http.Request, algo string) error {
+	body, err := readBody(request)
+	if err != nil {
+		return err
+	}
+	h, err := newHash(algo)
+	if err != nil {
+		return err
+	}
+	h.Write(body)
+	request.Header.Set("Upload-Checksum", algo+" "+base64Encode(h.Sum(nil)))
+	return nil
+}
+
+func base64Encode(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func newHTTPClient(opts *options) *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.skipTLSVerification || opts.clientCertificate != nil {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: opts.skipTLSVerification}
+	}
+	if opts.clientCertificate != nil {
+		tr.TLSClientConfig.Certificates = []tls.Certificate{*opts.clientCertificate}
+	}
+	transport := &uploaderTransport{userAgent: opts.userAgent, next: tr}
+	if len(opts.trustFingerprints) > 0 || opts.knownHosts != nil {
+		trust := &certificateTrust{fingerprints: opts.trustFingerprints, knownHosts: opts.knownHosts}
+		transport.next = &trustingTransport{base: tr, trust: trust, hosts: make(map[string]*http.Transport)}
+	}
+	if opts.uploadChecksum {
+		transport.checksumAlgo = opts.checksumAlgo
+	}
+	if opts.hmacKey != nil {
+		transport.signer = &hmacSigner{key: opts.hmacKey, header: opts.hmacHeader}
+	}
+	if len(opts.rateSchedule) > 0 {
+		transport.limiter = &rateLimiter{schedule: opts.rateSchedule}
+	}
+	if len(opts.headerTemplates) > 0 {
+		transport.headers = &headerTemplater{templates: opts.headerTemplates}
+	}
+	return &http.Client{Transport: transport}
+}
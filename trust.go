@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// addTrustFlags registers the flags trusting a self-signed certificate by its fingerprint.
+func addTrustFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringArray("trust-fingerprint", nil, "SHA-256 fingerprint of the certificate of the server to trust instead of verifying it, can be repeated. eg: 'AB:CD:...' as printed by openssl x509 -fingerprint -sha256")
+	flags.Bool("trust-on-first-use", false, "Ask to trust a certificate that does not verify the first time a host presents it, and refuse it if it changes later")
+	flags.String("known-hosts", defaultKnownHostsPath(), "File recording the fingerprints trusted with --trust-on-first-use")
+}
+
+func parseTrust(cmd *cobra.Command, opts *options) error {
+	flags := cmd.Flags()
+	fingerprints, err := flags.GetStringArray("trust-fingerprint")
+	if err != nil {
+		return err
+	}
+	for _, fingerprint := range fingerprints {
+		normalized, err := parseFingerprint(fingerprint)
+		if err != nil {
+			return err
+		}
+		opts.trustFingerprints = append(opts.trustFingerprints, normalized)
+	}
+	tofu, err := flags.GetBool("trust-on-first-use")
+	if err != nil {
+		return err
+	}
+	knownHostsPath, err := flags.GetString("known-hosts")
+	if err != nil {
+		return err
+	}
+	if tofu {
+		if knownHostsPath == "" {
+			return fmt.Errorf("--trust-on-first-use requires a --known-hosts file")
+		}
+		opts.knownHosts = &knownHosts{path: knownHostsPath}
+	}
+	if opts.skipTLSVerification && (len(opts.trustFingerprints) > 0 || tofu) {
+		return fmt.Errorf("--skip-ssl-verification cannot be combined with --trust-fingerprint or --trust-on-first-use")
+	}
+	return nil
+}
+
+func defaultKnownHostsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "tus-uploader", "known_hosts")
+}
+
+// parseFingerprint normalizes a SHA-256 fingerprint to lower case hex without separators.
+func parseFingerprint(fingerprint string) (string, error) {
+	normalized := strings.ToLower(strings.TrimPrefix(strings.ToLower(fingerprint), "sha256:"))
+	normalized = strings.NewReplacer(":", "", " ", "").Replace(normalized)
+	if decoded, err := hex.DecodeString(normalized); err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("Invalid --trust-fingerprint '%s'. It must be the 64 hex digits of a SHA-256 fingerprint", fingerprint)
+	}
+	return normalized, nil
+}
+
+func certificateFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// formatFingerprint prints a fingerprint like openssl: upper case bytes separated by colons.
+func formatFingerprint(fingerprint string) string {
+	var pairs []string
+	for i := 0; i+2 <= len(fingerprint); i += 2 {
+		pairs = append(pairs, strings.ToUpper(fingerprint[i:i+2]))
+	}
+	return strings.Join(pairs, ":")
+}
+
+// knownHosts is the file of the fingerprints trusted on first use: one "host:port fingerprint" per line.
+type knownHosts struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (k *knownHosts) load() (map[string]string, error) {
+	hosts := make(map[string]string)
+	content, err := ioutil.ReadFile(k.path)
+	if os.IsNotExist(err) {
+		return hosts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if toks := strings.Fields(line); len(toks) == 2 && !strings.HasPrefix(toks[0], "#") {
+			hosts[toks[0]] = toks[1]
+		}
+	}
+	return hosts, nil
+}
+
+// verify accepts the certificate of host when it is the one it presented the first time. A certificate seen for the
+// first time is trusted once confirmed on the terminal, unless noPrompt.
+func (k *knownHosts) verify(host, fingerprint string, noPrompt bool) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	hosts, err := k.load()
+	if err != nil {
+		return err
+	}
+	if known, ok := hosts[host]; ok {
+		if known == fingerprint {
+			return nil
+		}
+		return fmt.Errorf("The certificate of %s changed: its SHA-256 fingerprint is %s instead of %s. If the certificate of the appliance was renewed, remove %s from %s", host, formatFingerprint(fingerprint), formatFingerprint(known), host, k.path)
+	}
+	fi, err := os.Stdin.Stat()
+	if noPrompt || err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("The certificate of %s with the SHA-256 fingerprint %s is not trusted yet. Run with --trust-fingerprint %s or from a terminal to trust it", host, formatFingerprint(fingerprint), formatFingerprint(fingerprint))
+	}
+	fmt.Fprintf(os.Stderr, "The certificate of %s cannot be verified. Its SHA-256 fingerprint is\n  %s\nTrust it from now on? [y/N] ", host, formatFingerprint(fingerprint))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return fmt.Errorf("The certificate of %s is not trusted", host)
+	}
+	hosts[host] = fingerprint
+	var b strings.Builder
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s %s\n", name, hosts[name])
+	}
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(k.path, []byte(b.String()), 0600); err != nil {
+		return err
+	}
+	console.Printf("Recorded the certificate of %s in %s\n", host, k.path)
+	return nil
+}
+
+// certificateTrust verifies the certificates of the servers by their fingerprints rather than by their issuer.
+type certificateTrust struct {
+	fingerprints []string
+	knownHosts   *knownHosts
+	// noPrompt refuses a certificate seen for the first time instead of asking
+	noPrompt bool
+}
+
+// verify is the VerifyPeerCertificate of the connections to host:port.
+// Without a --trust-fingerprint, a certificate that verifies normally is accepted without asking.
+func (t *certificateTrust) verify(host, serverName string, rawCerts [][]byte) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("%s presented no certificate", host)
+	}
+	fingerprint := certificateFingerprint(rawCerts[0])
+	if len(t.fingerprints) > 0 {
+		for _, trusted := range t.fingerprints {
+			if trusted == fingerprint {
+				return nil
+			}
+		}
+		if t.knownHosts == nil {
+			return fmt.Errorf("The certificate of %s has the SHA-256 fingerprint %s, which is not a --trust-fingerprint", host, formatFingerprint(fingerprint))
+		}
+	}
+	if verifyCertificateChain(serverName, rawCerts) == nil {
+		return nil
+	}
+	return t.knownHosts.verify(host, fingerprint, t.noPrompt)
+}
+
+func verifyCertificateChain(serverName string, rawCerts [][]byte) error {
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{DNSName: serverName, Intermediates: intermediates})
+	return err
+}
+
+// trustingTransport has a transport per host so that the verification of a certificate knows the host it is for,
+// including when the connection goes through a proxy.
+type trustingTransport struct {
+	base  *http.Transport
+	trust *certificateTrust
+	mu    sync.Mutex
+	hosts map[string]*http.Transport
+}
+
+func (t *trustingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.URL.Scheme != "https" {
+		return t.base.RoundTrip(request)
+	}
+	host := request.URL.Host
+	if request.URL.Port() == "" {
+		host = net.JoinHostPort(request.URL.Hostname(), "443")
+	}
+	t.mu.Lock()
+	transport, ok := t.hosts[host]
+	if !ok {
+		transport = t.base.Clone()
+		tlsConfig := &tls.Config{}
+		if t.base.TLSClientConfig != nil {
+			tlsConfig = t.base.TLSClientConfig.Clone()
+		}
+		serverName := request.URL.Hostname()
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return t.trust.verify(host, serverName, rawCerts)
+		}
+		transport.TLSClientConfig = tlsConfig
+		if t.trust.knownHosts != nil {
+			// the handshake waits for the confirmation of a certificate seen for the first time
+			transport.TLSHandshakeTimeout = 0
+		}
+		t.hosts[host] = transport
+	}
+	t.mu.Unlock()
+	return transport.RoundTrip(request)
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/eventials/go-tus"
+)
+
+// newFileUpload creates the upload of a file with the metadata of the --metadata-file.
+// The file is read through digest when it is not nil.
+func newFileUpload(f *os.File, digest *streamDigest, opts *options) (*tus.Upload, error) {
+	upload, err := tus.NewUploadFromFile(f)
+	if err != nil {
+		return nil, err
+	}
+	if digest != nil {
+		upload = tus.NewUpload(digest, upload.Size(), upload.Metadata, upload.Fingerprint)
+	}
+	for k, v := range opts.metadata {
+		upload.Metadata[k] = v
+	}
+	return upload, nil
+}
+
+// preview runs the --dry-run or --estimate of a file.
+func preview(file string, client *tus.Client, opts *options) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if opts.verifyKeyring != "" {
+		if err := gpgVerify(opts.gpgProgram, opts.verifyKeyring, file); err != nil {
+			return err
+		}
+	}
+	if opts.dryRun {
+		return dryRun(f, client, opts)
+	}
+	return estimate(f, client, opts.checksumAlgo)
+}
+
+// uploadFile uploads a file to the target of the client and imports it in vRA when requested.
+func uploadFile(file string, client *tus.Client, opts *options) (*uploadResult, error) {
+	url := client.Url
+	result := &uploadResult{File: file, Target: url}
+	started := time.Now()
+	defer func() { result.Duration = time.Since(started) }()
+
+	f, err := os.Open(file)
+	if err != nil {
+		return result, err
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return result, err
+	}
+	result.Size = fi.Size()
+	if opts.ifChanged != "" {
+		entry, found, err := opts.journal.Get(file, url)
+		if err != nil {
+			return result, err
+		}
+		if found {
+			unchanged, err := unchangedSince(entry, fi, file, opts.ifChanged, opts.checksumAlgo)
+			if err != nil {
+				return result, err
+			}
+			if unchanged {
+				if totals != nil {
+					totals.complete(file, fi.Size())
+				}
+				console.Printf("Skipping %s: unchanged since its upload to %s on %s\n", file, url, entry.UploadedAt.Format(timestampFormat))
+				result.Status = "skipped"
+				return result, nil
+			}
+		}
+	}
+
+	if opts.maxSize > 0 && !opts.follow {
+		if err := checkMaxSize(file, fi.Size(), client, opts.maxSize); err != nil {
+			return result, err
+		}
+	}
+
+	if opts.verifyKeyring != "" {
+		if err := gpgVerify(opts.gpgProgram, opts.verifyKeyring, file); err != nil {
+			return result, err
+		}
+		console.Printf("Verified the signature of %s\n", file)
+	}
+
+	if !opts.noLock {
+		lock, err := acquireUploadLock(file, url)
+		if err != nil {
+			return result, err
+		}
+		defer lock.Release()
+	}
+
+	if opts.follow {
+		console.Printf("TUS Uploading %s to %s\n", file, url)
+		uploader, err := followFile(f, client, opts, result)
+		if err == nil && !opts.skipOffsetCheck {
+			err = verifyUploadOffset(client, uploader.Url(), result.Size)
+		}
+		if err != nil {
+			return result, err
+		}
+		// the file grew during the upload
+		if fi, err = f.Stat(); err != nil {
+			return result, err
+		}
+		return completeUpload(file, fi, nil, uploader, nil, client, opts, result)
+	}
+
+	if opts.encryptKey != nil {
+		encrypted, err := encryptToTempFile(opts.encryptKey, file)
+		if err != nil {
+			return result, err
+		}
+		defer os.Remove(encrypted)
+		if f, err = os.Open(encrypted); err != nil {
+			return result, err
+		}
+		defer f.Close()
+	}
+
+	console.Printf("TUS Uploading %s to %s\n", file, url)
+
+	// (Optional) Create a chan to notify upload status
+	uploadChan := make(chan tus.Upload, 1)
+	go func() {
+		for uploadStatus := range uploadChan {
+			console.Progress(file, uploadStatus)
+		}
+	}()
+
+	// hash the file while it is uploaded rather than reading it again for the checksum features. With --encrypt-key the
+	// checksums are the digests of the plaintext: the stream is the encrypted file, which differs in every run
+	var digest *streamDigest
+	if opts.encryptKey == nil && (opts.checksumSidecar || opts.uploadManifest || (opts.journal != nil && opts.ifChanged == "checksum")) {
+		if digest, err = newStreamDigest(f, opts.checksumAlgo); err != nil {
+			return result, err
+		}
+	}
+
+	// create an upload from a file.
+	upload, err := newFileUpload(f, digest, opts)
+	if err != nil {
+		return result, err
+	}
+	if opts.encryptKey != nil {
+		upload.Metadata["filename"] = filepath.Base(file) + ".enc"
+		upload.Metadata["encryption"] = encryptionName
+	}
+	if opts.resumeStore != nil {
+		if opts.encryptKey == nil {
+			if upload.Fingerprint, err = resumeFingerprint(file, url, fi); err != nil {
+				return result, err
+			}
+		} else {
+			// A later run encrypts the file with another nonce: only the retries of this run may resume
+			defer opts.resumeStore.Delete(upload.Fingerprint)
+		}
+	}
+	if opts.signKey != "" {
+		signature, err := gpgSign(opts.gpgProgram, opts.signKey, file)
+		if err != nil {
+			return result, err
+		}
+		upload.Metadata["signature"] = signature
+		upload.Metadata["signatureKey"] = opts.signKey
+		console.Printf("Signed %s with the key %s\n", file, opts.signKey)
+	}
+
+	var uploader *tus.Uploader
+	if opts.resumeOffset >= 0 {
+		resumeURL, found := opts.resumeStore.Get(upload.Fingerprint)
+		if !found {
+			return result, fmt.Errorf("No unfinished upload of this version of %s to %s in the resume store %s", file, url, opts.resumeStore.path)
+		}
+		if opts.resumeOffset > upload.Size() {
+			return result, fmt.Errorf("--resume-offset %d is past the end of %s (%d Bytes)", opts.resumeOffset, file, upload.Size())
+		}
+		if err := confirmResumeOffset(file, resumeURL, opts.resumeOffset, opts.yes); err != nil {
+			return result, err
+		}
+		uploader = tus.NewUploader(client, resumeURL, upload, opts.resumeOffset)
+	}
+
+	// Declare number of attempts
+	const attemps = 50
+	for i := 1; i <= attemps; i++ {
+		if isInterrupted() {
+			err = errInterrupted
+			break
+		}
+		result.Attempts = i
+		if i > 1 {
+			console.Retry(file, i, attemps)
+		}
+		// Create an uploader. The offset forced by --resume-offset is not checked with a HEAD request
+		if opts.resumeOffset >= 0 {
+			uploader = tus.NewUploader(client, uploader.Url(), upload, uploader.Offset())
+		} else {
+			uploader, err = client.CreateOrResumeUpload(upload)
+		}
+		if err != nil {
+			if i == 1 { // on the first error, see if the problem is recoverable or not
+				errMsg := err.Error()
+				if strings.Contains(errMsg, "403") || strings.Contains(errMsg, "401") || strings.Contains(errMsg, "404") || strings.Contains(errMsg, "400") {
+					break // Unrecoverable error
+				}
+			}
+			if i == attemps {
+				break
+			}
+			console.Printf("Error %v\nTrying again in 10 seconds\n", err)
+			if serr := sleep(time.Second * 10); serr != nil {
+				err = serr
+				break
+			}
+			continue
+		}
+		result.UploadURL = uploader.Url()
+		result.BundleID = bundleIDFromURL(uploader.Url())
+		if uploader.Offset() > 0 {
+			console.Printf("%s Resuming the upload to %s at %d Bytes\n", time.Now().Format(timestampFormat), uploader.Url(), uploader.Offset())
+		} else if i == 1 {
+			console.Printf("%s Starting the upload to %s\n", time.Now().Format(timestampFormat), uploader.Url())
+		}
+		// (Optional) Notify Upload Status
+		uploader.NotifyUploadProgress(uploadChan)
+		// Start upload to server, aborting after the current chunk when interrupted or when the file changes
+		uploaded := make(chan struct{})
+		go func(uploader *tus.Uploader) {
+			select {
+			case <-interrupted:
+				uploader.Abort()
+			case <-uploaded:
+			}
+		}(uploader)
+		var changed int32
+		abort := uploader.Abort
+		go watchSource(file, fi, uploaded, func() {
+			atomic.StoreInt32(&changed, 1)
+			abort()
+		})
+		err = uploader.Upload()
+		close(uploaded)
+		if atomic.LoadInt32(&changed) == 1 {
+			err = fmt.Errorf("%w: %s", errSourceChanged, file)
+			break
+		}
+		if err == nil && uploader.IsAborted() {
+			err = errInterrupted
+			break
+		}
+		if err != nil {
+			if i == attemps {
+				break
+			}
+			console.Printf("Error %v\nTrying again in 10 seconds\n", err)
+			if serr := sleep(time.Second * 10); serr != nil {
+				err = serr
+				break
+			}
+			continue
+		}
+		break
+	}
+
+	if err == nil && sourceChanged(file, fi) {
+		err = fmt.Errorf("%w: %s", errSourceChanged, file)
+	}
+	if err == nil && !opts.skipOffsetCheck {
+		err = verifyUploadOffset(client, uploader.Url(), upload.Size())
+	}
+	if err != nil {
+		return result, err
+	}
+	return completeUpload(file, fi, upload, uploader, digest, client, opts, result)
+}
+
+// completeUpload finishes an upload that reached the end of the file: it records it in the resume store, the journal
+// and the checksum sidecar, uploads the checksum manifest and imports the bundle. upload is nil for --follow.
+func completeUpload(file string, fi os.FileInfo, upload *tus.Upload, uploader *tus.Uploader, digest *streamDigest, client *tus.Client, opts *options, result *uploadResult) (*uploadResult, error) {
+	url := client.Url
+	result.Status = "uploaded"
+	if opts.resumeStore != nil && upload != nil {
+		opts.resumeStore.Delete(upload.Fingerprint)
+	}
+	if totals != nil {
+		totals.complete(file, fi.Size())
+	}
+	if upload != nil {
+		console.Progress(file, *upload)
+	}
+	console.Printf("%s Done uploading %s\n", time.Now().Format(timestampFormat), file)
+
+	if opts.checksumSidecar {
+		sum, err := digest.fileDigest(file, opts.checksumAlgo, fi.Size())
+		if err != nil {
+			return result, err
+		}
+		sidecar, err := writeChecksumSidecar(file, opts.checksumAlgo, sum)
+		if err != nil {
+			return result, err
+		}
+		console.Printf("Wrote %s %s\n", opts.checksumAlgo, sidecar)
+	}
+
+	if opts.journal != nil {
+		entry := journalEntry{
+			File:       file,
+			Target:     url,
+			Size:       fi.Size(),
+			ModTime:    fi.ModTime(),
+			UploadURL:  uploader.Url(),
+			UploadedAt: time.Now(),
+		}
+		if opts.ifChanged == "checksum" {
+			sum, err := digest.fileDigest(file, opts.checksumAlgo, fi.Size())
+			if err != nil {
+				return result, err
+			}
+			entry.Checksum = opts.checksumAlgo + ":" + sum
+		}
+		if err := opts.journal.Record(entry); err != nil {
+			console.Printf("Warning: unable to record the upload in the journal %s: %s\n", opts.journalPath, err.Error())
+		}
+	}
+
+	if opts.uploadManifest {
+		manifestURL, err := uploadChecksumManifest(file, uploader.Url(), digest, client, opts)
+		if err != nil {
+			return result, fmt.Errorf("Failed to upload the checksum manifest of %s: %s", file, err.Error())
+		}
+		result.ManifestURL = manifestURL
+		console.Printf("Uploaded the checksum manifest of %s to %s\n", file, manifestURL)
+	}
+
+	if opts.vraImport && clientToken(client) != "" {
+		var err error
+		result.ProviderName, result.ProviderVersion, result.IntegrationsURL, err = vraImportBundle(clientToken(client), client, uploader, client.Config, opts.importExtras)
+		if err != nil {
+			result.ImportStatus = "failed"
+			return result, err
+		}
+		result.ImportStatus = "imported"
+	}
+
+	return result, nil
+}
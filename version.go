@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/eventials/go-tus"
+	"github.com/spf13/cobra"
+)
+
+// Injected at build time. eg:
+// go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+// The TUS protocol extensions this client knows how to use.
+var tusExtensions = []string{"creation", "creation-defer-length", "checksum", "termination"}
+
+func versionInfo() string {
+	return fmt.Sprintf(`tus-uploader %s
+  Git commit:   %s
+  Build date:   %s
+  Go version:   %s %s/%s
+  TUS protocol: %s (extensions: %s)
+`, version, commit, date, runtime.Version(), runtime.GOOS, runtime.GOARCH, tus.ProtocolVersion, strings.Join(tusExtensions, ", "))
+}
+
+func newVersionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the version and build information",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Print(versionInfo())
+		},
+	}
+}
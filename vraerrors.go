@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	netURL "net/url"
+	"strings"
+
+	"github.com/eventials/go-tus"
+)
+
+const requestTrackerPath = "/iaas/api/request-tracker/"
+
+// vraErrorResponse is the body of a vRA API error.
+type vraErrorResponse struct {
+	Message          string   `json:"message"`
+	StatusCode       int      `json:"statusCode"`
+	ErrorCode        int      `json:"errorCode"`
+	ServerErrorID    string   `json:"serverErrorId"`
+	Details          []string `json:"details"`
+	RequestTrackerID string   `json:"requestTrackerId"`
+}
+
+// requestTracker is the state of an asynchronous vRA request.
+type requestTracker struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Progress int    `json:"progress"`
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+}
+
+// vraImportError prints the details of a failed import: the error reported by vRA and the state of
+// its request tracker when there is one, rather than just the status code.
+func vraImportError(bearerToken, importURL string, clientConfig *tus.Config, response *http.Response, body []byte) error {
+	console.Printf("The import failed with %s\n", response.Status)
+	var details vraErrorResponse
+	if err := json.Unmarshal(body, &details); err != nil || details.Message == "" {
+		console.Printf("  Response: %s\n", strings.TrimSpace(string(body)))
+		return fmt.Errorf("Failed to import the bundle. StatusCode was '%s' instead of 200/OK", response.Status)
+	}
+	console.Printf("  Message: %s\n", details.Message)
+	for _, detail := range details.Details {
+		console.Printf("  Detail: %s\n", detail)
+	}
+	if details.ErrorCode != 0 {
+		console.Printf("  Error code: %d\n", details.ErrorCode)
+	}
+	if details.ServerErrorID != "" {
+		console.Printf("  Server error id: %s (search it in the logs of the appliance)\n", details.ServerErrorID)
+	}
+	if details.RequestTrackerID != "" {
+		tracker, err := fetchRequestTracker(bearerToken, importURL, details.RequestTrackerID, clientConfig)
+		if err != nil {
+			console.Printf("  Unable to fetch the request tracker %s: %s\n", details.RequestTrackerID, err.Error())
+		} else {
+			console.Printf("  Request %s: %s at %d%% %s\n", tracker.ID, tracker.Status, tracker.Progress, tracker.Message)
+		}
+	}
+	return fmt.Errorf("Failed to import the bundle: %s. StatusCode was '%s' instead of 200/OK", details.Message, response.Status)
+}
+
+func fetchRequestTracker(bearerToken, importURL, id string, clientConfig *tus.Config) (*requestTracker, error) {
+	baseURL, err := netURL.Parse(importURL)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest("GET", baseURL.Scheme+"://"+baseURL.Host+requestTrackerPath+netURL.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+bearerToken)
+	response, err := clientConfig.HttpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("%s", response.Status)
+	}
+	tracker := &requestTracker{}
+	if err := json.Unmarshal(body, tracker); err != nil {
+		return nil, err
+	}
+	return tracker, nil
+}
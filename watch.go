@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+const (
+	sourceWatchInterval = time.Second
+	// maxSourceRestarts bounds the restarts of --on-change restart for a file that keeps changing
+	maxSourceRestarts = 3
+)
+
+var errSourceChanged = errors.New("The file changed during the upload")
+
+// sourceChanged tells whether a file is not the one stat-ed when its upload started anymore.
+func sourceChanged(file string, fi os.FileInfo) bool {
+	current, err := os.Stat(file)
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(fi, current) || current.Size() != fi.Size() || !current.ModTime().Equal(fi.ModTime())
+}
+
+// watchSource calls changed once when the file changes, until stop is closed.
+func watchSource(file string, fi os.FileInfo, stop <-chan struct{}, changed func()) {
+	ticker := time.NewTicker(sourceWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if sourceChanged(file, fi) {
+				changed()
+				return
+			}
+		}
+	}
+}